@@ -0,0 +1,27 @@
+package cache
+
+import (
+	"github.com/spf13/viper"
+	"github.com/stovak/gpt-subtitles/pkg/models"
+	"github.com/stovak/gpt-subtitles/pkg/util"
+)
+
+// Open returns the translation cache in the directory of the cache.dir config key
+func Open() *models.Cache {
+	return models.NewCache(util.ExpandHome(viper.GetString("cache.dir")))
+}
+
+// filtered returns the entries of the given engine and target language; empty filters match everything
+func filtered(entries []models.CacheEntry, engine string, target string) []models.CacheEntry {
+	var toReturn []models.CacheEntry
+	for _, entry := range entries {
+		if engine != "" && entry.Engine != engine {
+			continue
+		}
+		if target != "" && entry.TargetLanguage != target {
+			continue
+		}
+		toReturn = append(toReturn, entry)
+	}
+	return toReturn
+}
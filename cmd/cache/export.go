@@ -0,0 +1,47 @@
+package cache
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/stovak/gpt-subtitles/pkg/models"
+)
+
+// ExportCmd represents the cache:export command
+var ExportCmd = &cobra.Command{
+	Use:   "cache:export <file>",
+	Short: "Export the translation cache as CSV or JSON",
+	Long: `Writes the cached cues to a file. The CSV format has the columns source_language,
+target_language, source, target and can be passed back with --examples.
+
+  subtitles cache:export --target es cache-es.csv
+  subtitles cache:export --format json cache.json`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		engine, _ := cmd.Flags().GetString("engine")
+		target, _ := cmd.Flags().GetString("target")
+		format, _ := cmd.Flags().GetString("format")
+		entries, err := Open().Entries()
+		if err != nil {
+			return err
+		}
+		entries = filtered(entries, engine, target)
+		f, err := os.Create(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		err = models.ExportCache(f, entries, format)
+		if err != nil {
+			return err
+		}
+		cmd.Printf("Exported %d entries to %s\n", len(entries), args[0])
+		return nil
+	},
+}
+
+func init() {
+	ExportCmd.Flags().String("engine", "", "Only export entries of this engine")
+	ExportCmd.Flags().String("target", "", "Only export entries translated into this language")
+	ExportCmd.Flags().String("format", "csv", "Export format: csv or json")
+}
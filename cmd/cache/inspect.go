@@ -0,0 +1,53 @@
+package cache
+
+import (
+	"strings"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+	"github.com/stovak/gpt-subtitles/pkg/models"
+)
+
+// InspectCmd represents the cache:inspect command
+var InspectCmd = &cobra.Command{
+	Use:   "cache:inspect",
+	Short: "Show what is in the translation cache",
+	Long: `Summarizes the translation cache per engine, model, prompt template and language
+pair. With --search, lists the cached cues whose source or translation contains the text.
+
+  subtitles cache:inspect
+  subtitles cache:inspect --search "lawyer" --engine gpt`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		engine, _ := cmd.Flags().GetString("engine")
+		target, _ := cmd.Flags().GetString("target")
+		search, _ := cmd.Flags().GetString("search")
+		c := Open()
+		entries, err := c.Entries()
+		if err != nil {
+			return err
+		}
+		entries = filtered(entries, engine, target)
+		cmd.Printf("Cache %s\n", c.Dir)
+		if search == "" {
+			cmd.Println(models.CacheSummary(entries))
+			return nil
+		}
+		t := table.NewWriter()
+		t.AppendHeader(table.Row{"Engine", "Language", "Source", "Translation", "Hash"})
+		for _, entry := range entries {
+			if !strings.Contains(entry.Source, search) && !strings.Contains(entry.Translation, search) {
+				continue
+			}
+			t.AppendRow(table.Row{entry.Engine, entry.TargetLanguage, entry.Source, entry.Translation, entry.Hash[:12]})
+		}
+		cmd.Println(t.Render())
+		return nil
+	},
+}
+
+func init() {
+	InspectCmd.Flags().String("engine", "", "Only entries of this engine")
+	InspectCmd.Flags().String("target", "", "Only entries translated into this language")
+	InspectCmd.Flags().String("search", "", "List the entries whose source or translation contains this text")
+}
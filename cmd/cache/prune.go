@@ -0,0 +1,47 @@
+package cache
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stovak/gpt-subtitles/pkg/models"
+)
+
+// PruneCmd represents the cache:prune command
+var PruneCmd = &cobra.Command{
+	Use:   "cache:prune",
+	Short: "Remove entries from the translation cache",
+	Long: `Removes the cached cues matching all the given filters, e.g. everything older than
+30 days or everything translated by one engine. Use --all to empty the cache.
+
+  subtitles cache:prune --older-than 720h
+  subtitles cache:prune --engine gpt --target de`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		engine, _ := cmd.Flags().GetString("engine")
+		target, _ := cmd.Flags().GetString("target")
+		olderThan, _ := cmd.Flags().GetDuration("older-than")
+		all, _ := cmd.Flags().GetBool("all")
+		if !all && engine == "" && target == "" && olderThan == 0 {
+			return fmt.Errorf("give --older-than, --engine or --target, or --all to empty the cache")
+		}
+		cutoff := time.Now().Add(-olderThan)
+		c := Open()
+		removed, err := c.Prune(func(entry models.CacheEntry) bool {
+			if olderThan > 0 && entry.Created.After(cutoff) {
+				return false
+			}
+			return len(filtered([]models.CacheEntry{entry}, engine, target)) > 0
+		})
+		cmd.Printf("Removed %d entries from %s\n", removed, c.Dir)
+		return err
+	},
+}
+
+func init() {
+	PruneCmd.Flags().String("engine", "", "Only remove entries of this engine")
+	PruneCmd.Flags().String("target", "", "Only remove entries translated into this language")
+	PruneCmd.Flags().Duration("older-than", 0, "Only remove entries older than this, e.g. 720h")
+	PruneCmd.Flags().Bool("all", false, "Remove every entry")
+}
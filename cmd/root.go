@@ -10,8 +10,10 @@ import (
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
 
+	"github.com/stovak/gpt-subtitles/cmd/cache"
 	"github.com/stovak/gpt-subtitles/cmd/drop"
 	"github.com/stovak/gpt-subtitles/cmd/subs"
+	"github.com/stovak/gpt-subtitles/cmd/tm"
 )
 
 var (
@@ -58,14 +60,71 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.gpt-subtitles.yaml)")
 	rootCmd.PersistentFlags().StringP("sourceLanguage", "s", "en", "SourceLanguage... E.g. en for English")
 	rootCmd.PersistentFlags().StringP("targetLanguage", "t", "es", "DestinationLanguage... E.g. es for Spanish")
-	rootCmd.PersistentFlags().StringP("engine", "e", "gpt", "Translation Engine: google or gpt")
+	rootCmd.PersistentFlags().StringP("engine", "e", "gpt", "Translation Engine: google, gpt or hybrid (Google Translate draft post-edited by GPT)")
 	rootCmd.PersistentFlags().BoolVar(&enableDebug, "debug", os.Getenv("DEBUG") == "true", "Enable debug mode")
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
 
+	rootCmd.PersistentFlags().StringSlice("examples", nil, "CSV files of few-shot example pairs (source_language,target_language,source,target) for LLM engines")
+	_ = viper.BindPFlag("examples.files", rootCmd.PersistentFlags().Lookup("examples"))
+	viper.SetDefault("examples.limit", 10)
+	rootCmd.PersistentFlags().String("glossary", "", "Glossary file (CSV or TBX) of required term renderings and do-not-translate names")
+	_ = viper.BindPFlag("glossary", rootCmd.PersistentFlags().Lookup("glossary"))
+	rootCmd.PersistentFlags().StringSlice("dnt", nil, "Regular expressions for spans that must not be translated (URLs, hashtags, product names...)")
+	_ = viper.BindPFlag("dnt", rootCmd.PersistentFlags().Lookup("dnt"))
+	rootCmd.PersistentFlags().String("bible", "", "Show bible (YAML) with synopsis, characters, genders and relationships for LLM engines")
+	_ = viper.BindPFlag("bible", rootCmd.PersistentFlags().Lookup("bible"))
+	rootCmd.PersistentFlags().String("episode-bible", "", "Episode bible (YAML) layered over the show bible")
+	_ = viper.BindPFlag("episode-bible", rootCmd.PersistentFlags().Lookup("episode-bible"))
+	rootCmd.PersistentFlags().String("title", "", "Title the spend is booked against in the budget ledger (default is the file name)")
+	_ = viper.BindPFlag("title", rootCmd.PersistentFlags().Lookup("title"))
+	viper.SetDefault("budget.ledger", "~/.subtitles-ledger.json")
+	rootCmd.PersistentFlags().Bool("review", false, "Run an LLM self-review pass over the translation and log the corrections")
+	_ = viper.BindPFlag("review", rootCmd.PersistentFlags().Lookup("review"))
+	rootCmd.PersistentFlags().Bool("quality", false, "Score every translated cue with an LLM and report the low-confidence ones")
+	_ = viper.BindPFlag("quality.enabled", rootCmd.PersistentFlags().Lookup("quality"))
+	rootCmd.PersistentFlags().Int("quality-threshold", 70, "Quality score (0-100) below which a cue is flagged for review")
+	_ = viper.BindPFlag("quality.threshold", rootCmd.PersistentFlags().Lookup("quality-threshold"))
+	rootCmd.PersistentFlags().Float64("max-cps", 0, "Reading speed in characters per second that limits the length of every cue; longer cues are sent back to be shortened")
+	_ = viper.BindPFlag("length.cps", rootCmd.PersistentFlags().Lookup("max-cps"))
+	viper.SetDefault("length.line_length", 42)
+	viper.SetDefault("length.lines", 2)
+	viper.SetDefault("length.attempts", 2)
+	rootCmd.PersistentFlags().Bool("no-cache", false, "Do not read or write the translation cache")
+	_ = viper.BindPFlag("cache.disabled", rootCmd.PersistentFlags().Lookup("no-cache"))
+	viper.SetDefault("cache.dir", "~/.subtitles-cache")
+	rootCmd.PersistentFlags().Bool("no-tm", false, "Do not use exact matches from the translation memory")
+	_ = viper.BindPFlag("tm.disabled", rootCmd.PersistentFlags().Lookup("no-tm"))
+	viper.SetDefault("tm.file", "~/.subtitles-tm.json")
+	viper.SetDefault("tm.fuzzy", 0.7)
+	viper.SetDefault("tm.references", 3)
+	rootCmd.PersistentFlags().Float64("tm-accept", 0, "Similarity (0-1) from which a fuzzy translation memory match is used without the engine, e.g. 0.95 (default off)")
+	_ = viper.BindPFlag("tm.accept", rootCmd.PersistentFlags().Lookup("tm-accept"))
+	rootCmd.PersistentFlags().Bool("no-dedupe", false, "Send repeated cues to the engine every time instead of translating them once")
+	_ = viper.BindPFlag("dedupe.disabled", rootCmd.PersistentFlags().Lookup("no-dedupe"))
+	rootCmd.PersistentFlags().Bool("dedupe-context", false, "Only translate repeated cues once when their neighbouring cues are identical as well")
+	_ = viper.BindPFlag("dedupe.context", rootCmd.PersistentFlags().Lookup("dedupe-context"))
+	rootCmd.PersistentFlags().Bool("resume", false, "Continue an interrupted translation from its checkpoint instead of starting over")
+	_ = viper.BindPFlag("resume", rootCmd.PersistentFlags().Lookup("resume"))
+	viper.SetDefault("checkpoint.dir", "~/.subtitles-checkpoints")
+	rootCmd.PersistentFlags().Bool("patch", false, "Write TTML/DFXP and WebVTT translations by replacing only the text of every cue in the source file, keeping everything else as it is")
+	_ = viper.BindPFlag("patch", rootCmd.PersistentFlags().Lookup("patch"))
+	rootCmd.PersistentFlags().Bool("full", false, "Retranslate every cue instead of only the cues that changed since the last translation")
+	_ = viper.BindPFlag("full", rootCmd.PersistentFlags().Lookup("full"))
+	rootCmd.PersistentFlags().String("series", "", "Series memory file shared by the episodes of a series to keep recurring lines and names consistent")
+	_ = viper.BindPFlag("series.file", rootCmd.PersistentFlags().Lookup("series"))
+	viper.SetDefault("series.min_words", 3)
+	rootCmd.PersistentFlags().String("formality", "", "Register of the translation: formal, informal or auto (default from formality.<language> in the config file)")
+
+	rootCmd.AddCommand(subs.TranslateOneCmd)
 	rootCmd.AddCommand(subs.TranslateAllCmd)
-	rootCmd.AddCommand(subs.TranslateAllCmd)
+	rootCmd.AddCommand(subs.BackTranslateCmd)
+	rootCmd.AddCommand(cache.InspectCmd)
+	rootCmd.AddCommand(cache.PruneCmd)
+	rootCmd.AddCommand(cache.ExportCmd)
+	rootCmd.AddCommand(tm.ImportCmd)
+	rootCmd.AddCommand(tm.ExportCmd)
 	rootCmd.AddCommand(drop.ListCmd)
 
 }
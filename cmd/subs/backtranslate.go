@@ -0,0 +1,70 @@
+package subs
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/stovak/gpt-subtitles/pkg/models"
+)
+
+// BackTranslateCmd represents the subs:backtranslate command
+var BackTranslateCmd = &cobra.Command{
+	Use:   "subs:backtranslate <source file> <translated file>",
+	Short: "Translate a finished translation back into the source language and score each cue against the original",
+	Long: `Translates a finished target file back into the source language, possibly with a
+different engine than the one that produced it, and writes a per-cue report next to
+the translated file comparing the original with the back-translation. Cues scoring
+below the threshold are marked with "!!" so producers can sanity-check languages
+they cannot read.
+
+  subtitles subs:backtranslate -s en -t es -e google movie.ttml movie_es.ttml`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		source, err := cmd.Flags().GetString("sourceLanguage")
+		if err != nil {
+			return err
+		}
+		target, err := cmd.Flags().GetString("targetLanguage")
+		if err != nil {
+			return err
+		}
+		engine, err := cmd.Flags().GetString("engine")
+		if err != nil {
+			return err
+		}
+		threshold, err := cmd.Flags().GetFloat64("threshold")
+		if err != nil {
+			return err
+		}
+		original, err := models.OpenSubtitles(args[0])
+		if err != nil {
+			return err
+		}
+		tr, err := newEngineRequest(cmd, engine, args[1], target, source)
+		if err != nil {
+			return err
+		}
+		err = tr.Parse()
+		if err != nil {
+			return err
+		}
+		err = tr.Translate()
+		if err != nil {
+			return err
+		}
+		back, err := tr.GetTranslated()
+		if err != nil {
+			return err
+		}
+		rows, err := models.CompareBackTranslation(original, tr.GetBase().Subtitles, back)
+		if err != nil {
+			return err
+		}
+		report, flagged := models.BackTranslationReport(rows, threshold)
+		cmd.Println(report)
+		cmd.Printf("%d of %d cues score below %.2f\n", flagged, len(rows), threshold)
+		return tr.GetBase().WriteReport("backtranslation", report)
+	},
+}
+
+func init() {
+	BackTranslateCmd.Flags().Float64("threshold", 0.5, "Similarity score (0-1) below which a cue is flagged")
+}
@@ -0,0 +1,215 @@
+package subs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stovak/gpt-subtitles/cmd/cache"
+	"github.com/stovak/gpt-subtitles/cmd/tm"
+	"github.com/stovak/gpt-subtitles/pkg/models"
+	"github.com/stovak/gpt-subtitles/pkg/util"
+)
+
+// newTranslationRequest creates a translation request for the given engine and
+// applies the project configuration (examples, etc.) to it
+func newTranslationRequest(cmd *cobra.Command, engine string, fileName string, source string, target string) (models.TranslationRequest, error) {
+	tr, err := newEngineRequest(cmd, engine, fileName, source, target)
+	if err != nil {
+		return nil, err
+	}
+	return tr, configureRequest(tr)
+}
+
+// newEngineRequest creates a bare translation request for the given engine
+func newEngineRequest(cmd *cobra.Command, engine string, fileName string, source string, target string) (models.TranslationRequest, error) {
+	switch engine {
+	case "google":
+		cmd.Println("Using Google Translate")
+		return models.NewGoogleTranslationRequestFromFile(fileName, source, target, cmd)
+	case "gpt":
+		cmd.Println("Using GPT Translate")
+		return models.NewGPTTranslationRequestFromFile(fileName, source, target, cmd)
+	case "hybrid":
+		cmd.Println("Using Google Translate with GPT post-editing")
+		return models.NewHybridTranslationRequestFromFile(fileName, source, target, cmd)
+	default:
+		return nil, fmt.Errorf("unknown engine %s", engine)
+	}
+}
+
+// loadRates reads the price per model from the rates config key, e.g.
+//
+//	rates:
+//	  gpt-4: {prompt: 0.03, completion: 0.06}
+//	  nmt: {characters: 20}
+func loadRates() (models.RateTable, error) {
+	rates := models.RateTable{}
+	err := viper.UnmarshalKey("rates", &rates)
+	return rates, err
+}
+
+// usageOf returns the priced usage of a translation request and of the passes run after it
+func usageOf(cmd *cobra.Command, tr models.TranslationRequest, rates models.RateTable) []models.Usage {
+	toReturn := append([]models.Usage{tr.GetUsage()}, tr.GetPassUsage()...)
+	for i := range toReturn {
+		if !rates.Price(&toReturn[i]) {
+			cmd.Printf("No rate configured for model %s, cost not calculated\n", toReturn[i].Model)
+		}
+	}
+	return toReturn
+}
+
+func configureRequest(tr models.TranslationRequest) error {
+	base := tr.GetBase()
+	err := loadFormality(base)
+	if err != nil {
+		return err
+	}
+	err = loadGlossary(base)
+	if err != nil {
+		return err
+	}
+	base.Spans, err = models.NewSpanProtector(viper.GetStringSlice("dnt"))
+	if err != nil {
+		return err
+	}
+	err = loadBible(base)
+	if err != nil {
+		return err
+	}
+	if fileName := viper.GetString("series.file"); fileName != "" {
+		base.Series, err = models.LoadSeriesMemory(util.ExpandHome(fileName))
+		if err != nil {
+			return err
+		}
+		base.Series.MinWords = viper.GetInt("series.min_words")
+	}
+	if !viper.GetBool("full") {
+		base.Previous, err = models.LoadManifest(base.ManifestFileName())
+		if err != nil {
+			return err
+		}
+	}
+	base.Checkpoint = models.NewCheckpoint(util.ExpandHome(viper.GetString("checkpoint.dir")), base.SubtitleFileName, base.TargetLanguage.String())
+	base.Resume = viper.GetBool("resume")
+	base.Patch = viper.GetBool("patch")
+	if base.Patch {
+		err = models.CheckPatchable(base.SubtitleFileName)
+		if err != nil {
+			return err
+		}
+	}
+	if !viper.GetBool("cache.disabled") {
+		base.Cache = cache.Open()
+	}
+	if !viper.GetBool("dedupe.disabled") {
+		base.Dedupe = &models.Dedupe{}
+		err = viper.UnmarshalKey("dedupe", base.Dedupe)
+		if err != nil {
+			return err
+		}
+	}
+	if !viper.GetBool("tm.disabled") {
+		memory, err := tm.Open()
+		if err != nil {
+			return err
+		}
+		err = viper.UnmarshalKey("tm", &memory.Options)
+		if err != nil {
+			return err
+		}
+		if len(memory.Units) > 0 {
+			base.Memory = memory
+		}
+	}
+	if viper.GetBool("review") {
+		base.Reviewer = models.NewReviewer("gpt-review-request.tmpl")
+	}
+	var limits models.LengthLimits
+	err = viper.UnmarshalKey("length", &limits)
+	if err != nil {
+		return err
+	}
+	if limits.Enabled() {
+		base.Length = &limits
+		base.Shortener = models.NewShortener("gpt-shorten-request.tmpl")
+	}
+	if viper.GetBool("quality.enabled") {
+		base.Quality = models.NewQualityEstimator("gpt-quality-request.tmpl", viper.GetInt("quality.threshold"))
+	}
+	return loadExamples(base)
+}
+
+// loadBible reads the project show bible (bible config key) and layers the
+// episode bible given with --episode-bible over it
+func loadBible(base *models.TranslationRequestBase) error {
+	for _, fileName := range []string{viper.GetString("bible"), viper.GetString("episode-bible")} {
+		if fileName == "" {
+			continue
+		}
+		bible, err := models.LoadShowBible(fileName)
+		if err != nil {
+			return err
+		}
+		base.Bible = base.Bible.Merge(bible)
+	}
+	return nil
+}
+
+// loadGlossary reads the glossary file (CSV or TBX) given by --glossary or the glossary config key
+func loadGlossary(base *models.TranslationRequestBase) error {
+	fileName := viper.GetString("glossary")
+	if fileName == "" {
+		return nil
+	}
+	terms, err := models.LoadGlossaryFile(fileName, base.SourceLanguage, base.TargetLanguage)
+	if err != nil {
+		return fmt.Errorf("loading glossary from %s: %w", fileName, err)
+	}
+	base.Glossary = terms
+	return nil
+}
+
+// loadFormality uses the --formality flag when given, otherwise the
+// configured default for the target language (formality.<language>)
+func loadFormality(base *models.TranslationRequestBase) error {
+	value, err := base.Cmd.Flags().GetString("formality")
+	if err != nil {
+		return err
+	}
+	if value == "" {
+		lang, _ := base.TargetLanguage.Base()
+		value = viper.GetString("formality." + lang.String())
+	}
+	base.Formality, err = models.ParseFormality(value)
+	return err
+}
+
+// loadExamples adds the few-shot examples from the configured CSV files and
+// from previously approved translations of the configured source files
+func loadExamples(base *models.TranslationRequestBase) error {
+	for _, fileName := range viper.GetStringSlice("examples.files") {
+		examples, err := models.LoadExamplesFile(fileName, base.SourceLanguage, base.TargetLanguage)
+		if err != nil {
+			return fmt.Errorf("loading examples from %s: %w", fileName, err)
+		}
+		base.AddExamples(examples, 0)
+	}
+	for _, sourceFileName := range viper.GetStringSlice("examples.approved") {
+		targetFileName := models.TranslatedFileName(sourceFileName, base.TargetLanguage.String())
+		examples, err := models.LoadExamplesFromTranslation(sourceFileName, targetFileName)
+		if errors.Is(err, fs.ErrNotExist) {
+			base.Cmd.Printf("No approved %s translation of %s, skipping\n", base.TargetLanguage, sourceFileName)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("loading examples from %s: %w", targetFileName, err)
+		}
+		base.AddExamples(examples, 0)
+	}
+	base.AddExamples(nil, viper.GetInt("examples.limit"))
+	return nil
+}
@@ -0,0 +1,183 @@
+package subs
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stovak/gpt-subtitles/pkg/actions"
+	"github.com/stovak/gpt-subtitles/pkg/models"
+	"github.com/stovak/gpt-subtitles/pkg/util"
+)
+
+// translateLanguages translates fileName into each target language with the
+// given engine, honouring --dry-run and the budget caps, and writes the usage
+// (or estimate) report at the end. Languages that fail are reported and
+// skipped; exceeding the budget stops the run, keeping the finished files and
+// the checkpoint of the interrupted one.
+func translateLanguages(cmd *cobra.Command, fileName string, source string, engine string, targets []string) error {
+	rates, err := loadRates()
+	if err != nil {
+		return err
+	}
+	dryRun, err := cmd.Flags().GetBool("dry-run")
+	if err != nil {
+		return err
+	}
+	budget, ledger, err := loadBudget()
+	if err != nil {
+		return err
+	}
+	title := viper.GetString("title")
+	if title == "" {
+		title = strings.TrimSuffix(filepath.Base(fileName), filepath.Ext(fileName))
+	}
+
+	var report models.UsageReport
+	var errs []error
+	var runSpent float64
+	for _, target := range targets {
+		tr, err := newTranslationRequest(cmd, engine, fileName, source, target)
+		if err != nil {
+			cmd.Printf("Error creating request %s => %s: %s\n", source, target, err)
+			errs = append(errs, err)
+			continue
+		}
+		if dryRun {
+			estimate, err := actions.DryRun(tr)
+			if err != nil {
+				cmd.Printf("Error estimating %s => %s: %s\n", source, target, err)
+				errs = append(errs, err)
+				continue
+			}
+			passes, err := tr.EstimatePasses()
+			if err != nil {
+				cmd.Printf("Error estimating the passes of %s => %s: %s\n", source, target, err)
+				errs = append(errs, err)
+			}
+			for _, usage := range append([]models.Usage{estimate}, passes...) {
+				rates.Price(&usage)
+				report = append(report, usage)
+			}
+			continue
+		}
+		if budget.Enabled() {
+			tr, err = withinBudget(cmd, tr, budget, ledger, title, runSpent, rates)
+			if err != nil {
+				cmd.PrintErrf("Stopping before %s => %s: %s\n", source, target, err)
+				errs = append(errs, err)
+				break
+			}
+			tr.GetBase().Spending = spendingCheck(tr, budget, ledger, title, runSpent, rates)
+		}
+		err = actions.TranslateOne(tr)
+		if err != nil {
+			cmd.Printf("Error translating %s => %s: %s\n", source, target, err)
+			errs = append(errs, err)
+		}
+		stop := errors.Is(err, models.ErrBudgetExceeded)
+		for _, usage := range usageOf(cmd, tr, rates) {
+			report = append(report, usage)
+			runSpent += usage.Cost
+			if budget.Enabled() {
+				err = ledger.Record(title, usage, time.Now())
+				if err != nil {
+					errs = append(errs, err)
+				}
+			}
+		}
+		if stop {
+			break
+		}
+	}
+
+	name := "usage"
+	if dryRun {
+		name = "estimate"
+	}
+	errs = append(errs, actions.WriteUsageReport(cmd, fileName, name, report))
+	return errors.Join(errs...)
+}
+
+// loadBudget reads the budget caps from the budget config key, e.g.
+//
+//	budget:
+//	  run: 5
+//	  title: 40
+//	  day: 100
+//	  action: downgrade
+//	  fallback: google
+//	  ledger: ~/.subtitles-ledger.json
+func loadBudget() (models.Budget, *models.Ledger, error) {
+	var budget models.Budget
+	err := viper.UnmarshalKey("budget", &budget)
+	if err != nil || !budget.Enabled() {
+		return budget, nil, err
+	}
+	ledger, err := models.LoadLedger(util.ExpandHome(viper.GetString("budget.ledger")))
+	return budget, ledger, err
+}
+
+// withinBudget checks the projected cost of tr against the budget. When it
+// would go over a cap and the budget allows downgrading, the request is
+// rebuilt with the cheaper fallback engine and checked again.
+func withinBudget(cmd *cobra.Command, tr models.TranslationRequest, budget models.Budget, ledger *models.Ledger, title string, runSpent float64, rates models.RateTable) (models.TranslationRequest, error) {
+	cost, err := projectedCost(tr, rates)
+	if err != nil {
+		return tr, err
+	}
+	err = budget.Check(ledger, title, runSpent, cost, time.Now())
+	if err == nil || !budget.Downgrade() || tr.GetUsage().Engine == budget.Fallback {
+		return tr, err
+	}
+	cmd.Printf("%s, switching to the %s engine\n", err, budget.Fallback)
+	base := tr.GetBase()
+	fallback, err := newTranslationRequest(cmd, budget.Fallback, base.SubtitleFileName, base.SourceLanguage.String(), base.TargetLanguage.String())
+	if err != nil {
+		return tr, err
+	}
+	cost, err = projectedCost(fallback, rates)
+	if err != nil {
+		return fallback, err
+	}
+	return fallback, budget.Check(ledger, title, runSpent, cost, time.Now())
+}
+
+// projectedCost prices the dry run of a request and the estimates of its passes
+func projectedCost(tr models.TranslationRequest, rates models.RateTable) (float64, error) {
+	err := tr.Parse()
+	if err != nil {
+		return 0, err
+	}
+	estimate, _, err := tr.DryRun()
+	if err != nil {
+		return 0, err
+	}
+	passes, err := tr.EstimatePasses()
+	if err != nil {
+		return 0, err
+	}
+	var toReturn float64
+	for _, usage := range append([]models.Usage{estimate}, passes...) {
+		rates.Price(&usage)
+		toReturn += usage.Cost
+	}
+	return toReturn, nil
+}
+
+// spendingCheck returns a check of what tr has actually spent so far against
+// the budget, run before every LLM request so a translation stops as soon as
+// a cap is reached
+func spendingCheck(tr models.TranslationRequest, budget models.Budget, ledger *models.Ledger, title string, runSpent float64, rates models.RateTable) func() error {
+	return func() error {
+		var spent float64
+		for _, usage := range append([]models.Usage{tr.GetUsage()}, tr.GetPassUsage()...) {
+			rates.Price(&usage)
+			spent += usage.Cost
+		}
+		return budget.Check(ledger, title, runSpent, spent, time.Now())
+	}
+}
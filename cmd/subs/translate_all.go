@@ -4,11 +4,10 @@ Copyright © 2023 NAME HERE <EMAIL ADDRESS>
 package subs
 
 import (
-	"fmt"
 	"maps"
+	"slices"
 
 	"github.com/spf13/cobra"
-	"github.com/stovak/gpt-subtitles/pkg/actions"
 	"github.com/stovak/gpt-subtitles/pkg/models"
 )
 
@@ -22,8 +21,8 @@ and usage of using your command. For example:
 Cobra is a CLI library for Go that empowers applications.
 This application is a tool to generate the needed files
 to quickly create a Cobra application.`,
+	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		var tr models.TranslationRequest
 		cmd.Println("Root Command Exec:")
 		source, err := cmd.Flags().GetString("sourceLanguage")
 		if err != nil {
@@ -40,25 +39,10 @@ to quickly create a Cobra application.`,
 		// 2. remove the source language from the map
 		delete(langCopy, source)
 		// 3. for each language in the list, create a new translation request and send it to the translation engine
-		for k := range langCopy {
-			switch engine {
-			case "google":
-				cmd.Println("Using Google Translate")
-				tr, err = models.NewGoogleTranslationRequestFromFile(args[0], source, k, cmd)
-				break
-			case "gpt":
-				cmd.Println("Using GPT Translate")
-				tr, err = models.NewGPTTranslationRequestFromFile(args[0], source, k, cmd)
-				break
-			default:
-				cmd.Println("Unknown engine %s", engine)
-			}
-			err := actions.TranslateOne(tr)
-			if err != nil {
-				cmd.Println("Error translating %s => %s: %s", source, k, err)
-			}
-		}
-
-		return nil
+		return translateLanguages(cmd, args[0], source, engine, slices.Sorted(maps.Keys(langCopy)))
 	},
 }
+
+func init() {
+	TranslateAllCmd.Flags().Bool("dry-run", false, "Build the requests and estimate their cost without translating; the prompts are written next to the source file")
+}
@@ -0,0 +1,71 @@
+package tm
+
+import (
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stovak/gpt-subtitles/pkg/models"
+	"golang.org/x/text/language"
+)
+
+// ExportCmd represents the tm:export command
+var ExportCmd = &cobra.Command{
+	Use:   "tm:export <output.tmx> <source file> <translated file>...",
+	Short: "Export approved translations as TMX 1.4",
+	Long: `Pairs the cues of approved translations with the cues of their source file and
+writes them as a TMX 1.4 file for other CAT tools. The language of each translation is
+taken from its file name, e.g. es for movie_es.ttml. With --add the pairs are also added
+to the local translation memory.
+
+  subtitles tm:export -s en movie.tmx movie.ttml movie_es.ttml movie_de.ttml`,
+	Args: cobra.MinimumNArgs(3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sourceLanguage, err := cmd.Flags().GetString("sourceLanguage")
+		if err != nil {
+			return err
+		}
+		source, err := language.Parse(sourceLanguage)
+		if err != nil {
+			return err
+		}
+		add, _ := cmd.Flags().GetBool("add")
+		var units []models.MemoryUnit
+		now := time.Now()
+		for _, translated := range args[2:] {
+			target, err := models.TranslatedFileLanguage(args[1], translated)
+			if err != nil {
+				return err
+			}
+			fileUnits, err := models.LoadMemoryFromTranslation(args[1], translated, source, target, now)
+			if err != nil {
+				return err
+			}
+			cmd.Printf("%d cue pairs from %s\n", len(fileUnits), translated)
+			units = append(units, fileUnits...)
+		}
+		f, err := os.Create(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		err = models.WriteTMX(f, units, source.String())
+		if err != nil {
+			return err
+		}
+		cmd.Printf("Exported %d units to %s\n", len(units), args[0])
+		if !add {
+			return nil
+		}
+		memory, err := Open()
+		if err != nil {
+			return err
+		}
+		cmd.Printf("Added %d new units to %s\n", memory.Add(units...), memory.FileName)
+		return memory.Save()
+	},
+}
+
+func init() {
+	ExportCmd.Flags().Bool("add", false, "Also add the pairs to the local translation memory")
+}
@@ -0,0 +1,41 @@
+package tm
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/stovak/gpt-subtitles/pkg/models"
+)
+
+// ImportCmd represents the tm:import command
+var ImportCmd = &cobra.Command{
+	Use:   "tm:import <file.tmx>...",
+	Short: "Import TMX files from other CAT tools into the local translation memory",
+	Long: `Adds the translation units of TMX files to the local translation memory. Cues that
+exactly match a segment of the memory are translated from it instead of by the engine.
+Segments already in the memory get the imported translation.
+
+  subtitles tm:import trados-export.tmx`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		memory, err := Open()
+		if err != nil {
+			return err
+		}
+		for _, fileName := range args {
+			f, err := os.Open(fileName)
+			if err != nil {
+				return err
+			}
+			units, err := models.ReadTMX(f, fileName)
+			f.Close()
+			if err != nil {
+				return err
+			}
+			added := memory.Add(units...)
+			cmd.Printf("Imported %d units from %s, %d new\n", len(units), fileName, added)
+		}
+		cmd.Printf("Writing %d units to %s\n", len(memory.Units), memory.FileName)
+		return memory.Save()
+	},
+}
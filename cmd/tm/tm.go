@@ -0,0 +1,12 @@
+package tm
+
+import (
+	"github.com/spf13/viper"
+	"github.com/stovak/gpt-subtitles/pkg/models"
+	"github.com/stovak/gpt-subtitles/pkg/util"
+)
+
+// Open reads the local translation memory from the file of the tm.file config key
+func Open() (*models.TranslationMemory, error) {
+	return models.LoadTranslationMemory(util.ExpandHome(viper.GetString("tm.file")))
+}
@@ -0,0 +1,33 @@
+package actions
+
+import (
+	"fmt"
+
+	"github.com/stovak/gpt-subtitles/pkg/models"
+)
+
+// DryRun builds every request the engine would send without sending it,
+// writes the rendered prompts next to the source file and returns the
+// estimated usage
+func DryRun(tr models.TranslationRequest) (models.Usage, error) {
+	tr.GetCmd().Printf("Dry run %s => %s", tr.GetSourceLanguage(), tr.GetTargetLanguage())
+	estimate := tr.GetUsage()
+	err := tr.Parse()
+	if err != nil {
+		tr.GetCmd().PrintErrf("%s => %s:Error parsing file: %s", tr.GetSourceLanguage(), tr.GetTargetLanguage(), err)
+		return estimate, err
+	}
+	usage, prompts, err := tr.DryRun()
+	if err != nil {
+		tr.GetCmd().PrintErrf("%s => %s:Error building requests: %s", tr.GetSourceLanguage(), tr.GetTargetLanguage(), err)
+		return estimate, err
+	}
+	for i, prompt := range prompts {
+		err = tr.GetBase().WriteReport(fmt.Sprintf("%s-prompt-%d", tr.GetTargetLanguage(), i+1), prompt)
+		if err != nil {
+			return estimate, err
+		}
+	}
+	estimate.Add(usage)
+	return estimate, nil
+}
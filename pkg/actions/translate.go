@@ -1,8 +1,10 @@
 package actions
 
 import (
-	"github.com/stovak/gpt-subtitles/pkg/models"
+	"fmt"
 	"strings"
+
+	"github.com/stovak/gpt-subtitles/pkg/models"
 )
 
 func TranslateOne(tr models.TranslationRequest) error {
@@ -22,15 +24,115 @@ func TranslateOne(tr models.TranslationRequest) error {
 		tr.GetCmd().PrintErrf("%s => %s:Error getting translated file: %s", tr.GetSourceLanguage(), tr.GetTargetLanguage(), err)
 		return tr.WriteErrorDiff(tr.GetTranslatedText())
 	}
-	buff := new(strings.Builder)
-	err = translated.WriteToTTML(buff)
-	if err != nil {
-		tr.GetCmd().PrintErrf("%s => %s:Error writing translated file: %s", tr.GetSourceLanguage(), tr.GetTargetLanguage(), err)
-		return tr.WriteErrorDiff(tr.GetTranslatedText())
+	if edits := tr.GetPostEdits(); len(edits) > 0 {
+		err = tr.GetBase().WriteEditLog("post-edit", edits)
+		if err != nil {
+			tr.GetCmd().PrintErrf("%s => %s:Error writing post-edit log: %s", tr.GetSourceLanguage(), tr.GetTargetLanguage(), err)
+		}
+	}
+	if hits := tr.GetBase().MemoryHits; len(hits) > 0 {
+		tr.GetCmd().Printf("%s => %s:%d cues translated from the translation memory", tr.GetSourceLanguage(), tr.GetTargetLanguage(), len(hits))
+		err = tr.GetBase().WriteMemoryReport(tr.GetTranslatedText())
+		if err != nil {
+			tr.GetCmd().PrintErrf("%s => %s:Error writing translation memory report: %s", tr.GetSourceLanguage(), tr.GetTargetLanguage(), err)
+		}
+	}
+	if reviewer := tr.GetBase().Reviewer; reviewer != nil {
+		edits, err := reviewer.Review(tr.GetBase(), translated)
+		if err != nil {
+			tr.GetCmd().PrintErrf("%s => %s:Error reviewing translation, keeping %d edits: %s", tr.GetSourceLanguage(), tr.GetTargetLanguage(), len(edits), err)
+		}
+		tr.GetCmd().Printf("%s => %s:Review changed %d cues", tr.GetSourceLanguage(), tr.GetTargetLanguage(), len(edits))
+		if len(edits) > 0 {
+			err = tr.GetBase().WriteEditLog("review", edits)
+			if err != nil {
+				tr.GetCmd().PrintErrf("%s => %s:Error writing review log: %s", tr.GetSourceLanguage(), tr.GetTargetLanguage(), err)
+			}
+		}
 	}
-	err = tr.WriteToFile(tr.GetTargetLanguage().String(), buff.String())
+	if shortener := tr.GetBase().Shortener; shortener != nil {
+		edits, overflowing, err := shortener.Shorten(tr.GetBase(), translated)
+		if err != nil {
+			tr.GetCmd().PrintErrf("%s => %s:Error shortening cues, keeping %d edits: %s", tr.GetSourceLanguage(), tr.GetTargetLanguage(), len(edits), err)
+		}
+		tr.GetCmd().Printf("%s => %s:Shortened %d cues, %d still too long", tr.GetSourceLanguage(), tr.GetTargetLanguage(), len(edits), len(overflowing))
+		if len(edits) > 0 {
+			err = tr.GetBase().WriteEditLog("shorten", edits)
+			if err != nil {
+				tr.GetCmd().PrintErrf("%s => %s:Error writing shorten log: %s", tr.GetSourceLanguage(), tr.GetTargetLanguage(), err)
+			}
+		}
+		if len(overflowing) > 0 {
+			err = tr.GetBase().WriteLengthReport(translated, overflowing)
+			if err != nil {
+				tr.GetCmd().PrintErrf("%s => %s:Error writing length report: %s", tr.GetSourceLanguage(), tr.GetTargetLanguage(), err)
+			}
+		}
+	}
+	violations := tr.GetBase().VerifyGlossary(translated)
+	if len(violations) > 0 {
+		tr.GetCmd().PrintErrf("%s => %s:%d cues do not use the required glossary terms", tr.GetSourceLanguage(), tr.GetTargetLanguage(), len(violations))
+		err = tr.GetBase().WriteGlossaryReport(violations)
+		if err != nil {
+			tr.GetCmd().PrintErrf("%s => %s:Error writing glossary report: %s", tr.GetSourceLanguage(), tr.GetTargetLanguage(), err)
+		}
+	}
+	if tr.GetBase().Series != nil {
+		violations := tr.GetBase().VerifySeries(translated)
+		if len(violations) > 0 {
+			tr.GetCmd().PrintErrf("%s => %s:%d cues deviate from earlier episodes", tr.GetSourceLanguage(), tr.GetTargetLanguage(), len(violations))
+			err = tr.GetBase().WriteSeriesReport(violations)
+			if err != nil {
+				tr.GetCmd().PrintErrf("%s => %s:Error writing series report: %s", tr.GetSourceLanguage(), tr.GetTargetLanguage(), err)
+			}
+		}
+	}
+	if quality := tr.GetBase().Quality; quality != nil {
+		scores, err := quality.Estimate(tr.GetBase(), translated)
+		if err != nil {
+			tr.GetCmd().PrintErrf("%s => %s:Error estimating quality, keeping %d scores: %s", tr.GetSourceLanguage(), tr.GetTargetLanguage(), len(scores), err)
+		}
+		flagged := quality.Flagged(scores)
+		tr.GetCmd().Printf("%s => %s:%d of %d cues scored below %d", tr.GetSourceLanguage(), tr.GetTargetLanguage(), len(flagged), len(translated.Items), quality.Threshold)
+		if len(scores) > 0 {
+			err = tr.GetBase().WriteQualityScores(scores)
+			if err == nil {
+				err = tr.GetBase().WriteReport(fmt.Sprintf("%s-quality", tr.GetTargetLanguage()), models.QualityReport(flagged, len(translated.Items)))
+			}
+			if err != nil {
+				tr.GetCmd().PrintErrf("%s => %s:Error writing quality report: %s", tr.GetSourceLanguage(), tr.GetTargetLanguage(), err)
+			}
+		}
+	}
+	if tr.GetBase().Patch {
+		err = tr.GetBase().WritePatched(translated)
+		if err != nil {
+			tr.GetCmd().PrintErrf("%s => %s:Error writing patched file: %s", tr.GetSourceLanguage(), tr.GetTargetLanguage(), err)
+			return err
+		}
+	} else {
+		buff := new(strings.Builder)
+		err = models.WriteTTML(buff, translated, tr.GetTargetLanguage())
+		if err != nil {
+			tr.GetCmd().PrintErrf("%s => %s:Error writing translated file: %s", tr.GetSourceLanguage(), tr.GetTargetLanguage(), err)
+			return tr.WriteErrorDiff(tr.GetTranslatedText())
+		}
+		err = tr.WriteToFile(tr.GetTargetLanguage().String(), buff.String())
+		if err != nil {
+			tr.GetCmd().PrintErrf("%s => %s:Error writing translated file: %s", tr.GetSourceLanguage(), tr.GetTargetLanguage(), err)
+			return err
+		}
+	}
+	err = tr.GetBase().WriteManifest(translated)
 	if err != nil {
-		tr.GetCmd().PrintErrf("%s => %s:Error writing translated file: %s", tr.GetSourceLanguage(), tr.GetTargetLanguage(), err)
+		tr.GetCmd().PrintErrf("%s => %s:Error writing manifest: %s", tr.GetSourceLanguage(), tr.GetTargetLanguage(), err)
+		return err
+	}
+	if tr.GetBase().Series != nil {
+		err = tr.GetBase().RecordSeries(translated)
+		if err != nil {
+			tr.GetCmd().PrintErrf("%s => %s:Error writing series memory: %s", tr.GetSourceLanguage(), tr.GetTargetLanguage(), err)
+		}
 	}
 	return err
 }
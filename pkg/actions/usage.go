@@ -0,0 +1,23 @@
+package actions
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/stovak/gpt-subtitles/pkg/models"
+)
+
+// WriteUsageReport prints the usage of a run as a table and writes it as JSON
+// next to the source file, e.g. movie_usage.json for the name "usage"
+func WriteUsageReport(cmd *cobra.Command, fileName string, name string, report models.UsageReport) error {
+	cmd.Println(report.Table())
+	contents, err := report.JSON()
+	if err != nil {
+		return err
+	}
+	reportFileName := strings.TrimSuffix(fileName, filepath.Ext(fileName)) + "_" + name + ".json"
+	cmd.Printf("Writing %s report to %s\n", name, reportFileName)
+	return os.WriteFile(reportFileName, []byte(contents), 0644)
+}
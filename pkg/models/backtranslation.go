@@ -0,0 +1,65 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/asticode/go-astisub"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/stovak/gpt-subtitles/pkg/util"
+)
+
+// BackTranslation compares a source cue with the back-translation of its translation
+type BackTranslation struct {
+	Index          int
+	StartAt        time.Duration
+	EndAt          time.Duration
+	Original       string
+	Translated     string
+	BackTranslated string
+	Score          float64
+}
+
+// CompareBackTranslation scores every cue of the original against its
+// back-translation. All three files must have the same number of cues.
+func CompareBackTranslation(original *astisub.Subtitles, translated *astisub.Subtitles, back *astisub.Subtitles) ([]BackTranslation, error) {
+	if len(original.Items) != len(translated.Items) || len(translated.Items) != len(back.Items) {
+		return nil, fmt.Errorf("number of cues differ: original %d, translated %d, back-translated %d", len(original.Items), len(translated.Items), len(back.Items))
+	}
+	var toReturn []BackTranslation
+	for i, item := range original.Items {
+		toReturn = append(toReturn, BackTranslation{
+			Index:          i,
+			StartAt:        item.StartAt,
+			EndAt:          item.EndAt,
+			Original:       cueText(item),
+			Translated:     cueText(translated.Items[i]),
+			BackTranslated: cueText(back.Items[i]),
+			Score:          util.Similarity(cueText(item), cueText(back.Items[i])),
+		})
+	}
+	return toReturn, nil
+}
+
+// BackTranslationReport renders the comparison as a table, marking the cues
+// scoring below threshold with "!!". It also returns the number of those cues.
+func BackTranslationReport(rows []BackTranslation, threshold float64) (string, int) {
+	t := table.NewWriter()
+	t.AppendHeader(table.Row{"", "#", "Time", "Original", "Translated", "Back-translated", "Score"})
+	flagged := 0
+	for _, row := range rows {
+		marker := ""
+		if row.Score < threshold {
+			marker = "!!"
+			flagged++
+		}
+		t.AppendRow(table.Row{marker, row.Index + 1, FormatTimecode(row.StartAt), row.Original, row.Translated, row.BackTranslated, fmt.Sprintf("%.2f", row.Score)})
+	}
+	t.AppendFooter(table.Row{"", "", "", "", "", "Below threshold", fmt.Sprintf("%d/%d", flagged, len(rows))})
+	return t.Render(), flagged
+}
+
+// FormatTimecode renders a cue time as hh:mm:ss.mmm
+func FormatTimecode(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60, d.Milliseconds()%1000)
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stovak/gpt-subtitles/pkg/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareBackTranslation(t *testing.T) {
+	tr := &TranslationRequestBase{
+		SubtitleFileName: path.Join(util.GetRoot(), "test-fixtures", "TestFixture1.ttml"),
+		Cmd:              &cobra.Command{},
+	}
+	assert.NoError(t, tr.Parse(), "Parse()")
+	translated := translatedCopy(tr, func(s string) string { return "ES " + s })
+	back := translatedCopy(tr, func(s string) string { return s })
+	setItemText(back.Items[9], "Where is the beach?")
+
+	rows, err := CompareBackTranslation(tr.Subtitles, translated, back)
+	assert.NoError(t, err, "CompareBackTranslation()")
+	assert.Len(t, rows, len(tr.Subtitles.Items))
+	assert.Equal(t, 1.0, rows[0].Score)
+	assert.Less(t, rows[9].Score, 0.5)
+
+	report, flagged := BackTranslationReport(rows, 0.5)
+	assert.Equal(t, 1, flagged)
+	assert.Contains(t, report, "00:02:46.375")
+	assert.True(t, strings.Contains(report, "| !! |  10 |"), "the low scoring cue is not marked:\n%s", report)
+
+	back.Items = back.Items[1:]
+	_, err = CompareBackTranslation(tr.Subtitles, translated, back)
+	assert.Error(t, err, "cue counts differ")
+}
+
+func TestFormatTimecode(t *testing.T) {
+	assert.Equal(t, "01:02:03.045", FormatTimecode(time.Hour+2*time.Minute+3*time.Second+45*time.Millisecond))
+}
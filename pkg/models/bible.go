@@ -0,0 +1,146 @@
+package models
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ShowBible describes the show being translated so LLM engines know who is
+// speaking to whom. Gendered target languages need it to pick the right
+// agreement and forms of address.
+type ShowBible struct {
+	Title      string      `yaml:"title"`
+	Synopsis   string      `yaml:"synopsis"`
+	Setting    string      `yaml:"setting"`
+	Tone       string      `yaml:"tone"`
+	Characters []Character `yaml:"characters"`
+	// Notes are free-form remarks, typically about the episode being translated
+	Notes []string `yaml:"notes"`
+}
+
+// Character is a person appearing in the show
+type Character struct {
+	Name          string            `yaml:"name"`
+	Gender        string            `yaml:"gender"`
+	Pronouns      string            `yaml:"pronouns"`
+	Description   string            `yaml:"description"`
+	Relationships map[string]string `yaml:"relationships"`
+}
+
+// LoadShowBible reads a show bible from a YAML file
+func LoadShowBible(fileName string) (*ShowBible, error) {
+	contents, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	toReturn := &ShowBible{}
+	err = yaml.Unmarshal(contents, toReturn)
+	if err != nil {
+		return nil, fmt.Errorf("parsing show bible %s: %w", fileName, err)
+	}
+	return toReturn, nil
+}
+
+// Merge layers an episode bible over the project bible. Non-empty fields of
+// the episode replace the project's, characters are merged by name and notes
+// are appended.
+func (b *ShowBible) Merge(episode *ShowBible) *ShowBible {
+	if b == nil {
+		return episode
+	}
+	if episode == nil {
+		return b
+	}
+	merged := *b
+	merged.Title = firstNonEmpty(episode.Title, b.Title)
+	merged.Synopsis = firstNonEmpty(episode.Synopsis, b.Synopsis)
+	merged.Setting = firstNonEmpty(episode.Setting, b.Setting)
+	merged.Tone = firstNonEmpty(episode.Tone, b.Tone)
+	merged.Notes = append(append([]string{}, b.Notes...), episode.Notes...)
+	merged.Characters = append([]Character{}, b.Characters...)
+	for _, character := range episode.Characters {
+		found := false
+		for i := range merged.Characters {
+			if strings.EqualFold(merged.Characters[i].Name, character.Name) {
+				merged.Characters[i] = merged.Characters[i].merge(character)
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged.Characters = append(merged.Characters, character)
+		}
+	}
+	return &merged
+}
+
+func (c Character) merge(episode Character) Character {
+	c.Gender = firstNonEmpty(episode.Gender, c.Gender)
+	c.Pronouns = firstNonEmpty(episode.Pronouns, c.Pronouns)
+	c.Description = firstNonEmpty(episode.Description, c.Description)
+	relationships := maps.Clone(c.Relationships)
+	if relationships == nil {
+		relationships = map[string]string{}
+	}
+	maps.Copy(relationships, episode.Relationships)
+	c.Relationships = relationships
+	return c
+}
+
+// String renders the bible as prompt text
+func (b *ShowBible) String() string {
+	if b == nil {
+		return ""
+	}
+	buf := new(strings.Builder)
+	writeField := func(label string, value string) {
+		if value != "" {
+			fmt.Fprintf(buf, "%s: %s\n", label, strings.TrimSpace(value))
+		}
+	}
+	writeField("Title", b.Title)
+	writeField("Synopsis", b.Synopsis)
+	writeField("Setting", b.Setting)
+	writeField("Tone", b.Tone)
+	if len(b.Characters) > 0 {
+		buf.WriteString("Characters:\n")
+	}
+	for _, c := range b.Characters {
+		var details []string
+		if c.Gender != "" {
+			details = append(details, c.Gender)
+		}
+		if c.Pronouns != "" {
+			details = append(details, c.Pronouns)
+		}
+		fmt.Fprintf(buf, "- %s", c.Name)
+		if len(details) > 0 {
+			fmt.Fprintf(buf, " (%s)", strings.Join(details, ", "))
+		}
+		if c.Description != "" {
+			fmt.Fprintf(buf, ": %s", strings.TrimSpace(c.Description))
+		}
+		buf.WriteString("\n")
+		for _, other := range slices.Sorted(maps.Keys(c.Relationships)) {
+			fmt.Fprintf(buf, "  - relationship to %s: %s\n", other, c.Relationships[other])
+		}
+	}
+	for _, note := range b.Notes {
+		fmt.Fprintf(buf, "Note: %s\n", strings.TrimSpace(note))
+	}
+	return buf.String()
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
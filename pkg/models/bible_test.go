@@ -0,0 +1,61 @@
+package models
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadShowBible(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "bible.yaml")
+	err := os.WriteFile(fileName, []byte(`
+title: Interrogation
+synopsis: A journalist is questioned about two murders.
+characters:
+  - name: Detective
+    gender: female
+    pronouns: she/her
+    relationships:
+      Journalist: interrogator
+  - name: Journalist
+    gender: female
+`), 0644)
+	assert.NoError(t, err, "WriteFile()")
+	bible, err := LoadShowBible(fileName)
+	assert.NoError(t, err, "LoadShowBible()")
+	assert.Len(t, bible.Characters, 2)
+	assert.Equal(t, "interrogator", bible.Characters[0].Relationships["Journalist"])
+}
+
+func TestShowBible_Merge(t *testing.T) {
+	project := &ShowBible{
+		Title: "Interrogation",
+		Tone:  "dry, sarcastic",
+		Characters: []Character{
+			{Name: "Detective", Gender: "female", Relationships: map[string]string{"Journalist": "interrogator"}},
+		},
+	}
+	episode := &ShowBible{
+		Synopsis: "Episode 1: the journalist is brought in.",
+		Characters: []Character{
+			{Name: "detective", Pronouns: "she/her", Relationships: map[string]string{"Lawyer": "adversary"}},
+			{Name: "Lawyer", Gender: "female"},
+		},
+		Notes: []string{"The lawyer never appears on screen."},
+	}
+	merged := project.Merge(episode)
+	assert.Equal(t, "Interrogation", merged.Title)
+	assert.Equal(t, "Episode 1: the journalist is brought in.", merged.Synopsis)
+	assert.Len(t, merged.Characters, 2)
+	assert.Equal(t, "she/her", merged.Characters[0].Pronouns)
+	assert.Len(t, merged.Characters[0].Relationships, 2)
+	assert.Len(t, project.Characters[0].Relationships, 1, "Merge() must not modify the project bible")
+
+	prompt := merged.String()
+	assert.Contains(t, prompt, "Tone: dry, sarcastic\n")
+	assert.Contains(t, prompt, "- Detective (female, she/her)\n  - relationship to Journalist: interrogator\n  - relationship to Lawyer: adversary\n")
+	assert.Contains(t, prompt, "Note: The lawyer never appears on screen.\n")
+	assert.Empty(t, (*ShowBible)(nil).String())
+}
@@ -0,0 +1,134 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ErrBudgetExceeded is returned when spending more would go over a budget cap
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+// Budget caps what may be spent per run, per title and per day. A zero cap
+// is unlimited. When a cap would be exceeded the run either aborts or, with
+// the "downgrade" action, switches to the Fallback engine.
+type Budget struct {
+	Run      float64 `mapstructure:"run"`
+	Title    float64 `mapstructure:"title"`
+	Day      float64 `mapstructure:"day"`
+	Action   string  `mapstructure:"action"`
+	Fallback string  `mapstructure:"fallback"`
+}
+
+// Enabled reports whether any cap is set
+func (b Budget) Enabled() bool {
+	return b.Run > 0 || b.Title > 0 || b.Day > 0
+}
+
+// Downgrade reports whether the budget switches to a cheaper engine instead of aborting
+func (b Budget) Downgrade() bool {
+	return b.Action == "downgrade" && b.Fallback != ""
+}
+
+// Check returns ErrBudgetExceeded if spending cost on title would go over a
+// cap, given what this run and the ledger have already spent
+func (b Budget) Check(ledger *Ledger, title string, runSpent float64, cost float64, now time.Time) error {
+	if b.Run > 0 && runSpent+cost > b.Run {
+		return fmt.Errorf("%w: run would cost $%.4f of $%.2f", ErrBudgetExceeded, runSpent+cost, b.Run)
+	}
+	if b.Title > 0 {
+		spent := ledger.SpentOnTitle(title)
+		if spent+cost > b.Title {
+			return fmt.Errorf("%w: %s would cost $%.4f of $%.2f", ErrBudgetExceeded, title, spent+cost, b.Title)
+		}
+	}
+	if b.Day > 0 {
+		spent := ledger.SpentOn(now)
+		if spent+cost > b.Day {
+			return fmt.Errorf("%w: today would cost $%.4f of $%.2f", ErrBudgetExceeded, spent+cost, b.Day)
+		}
+	}
+	return nil
+}
+
+// LedgerEntry is the recorded cost of one translation
+type LedgerEntry struct {
+	Time           time.Time `json:"time"`
+	Title          string    `json:"title"`
+	File           string    `json:"file"`
+	TargetLanguage string    `json:"target_language"`
+	Engine         string    `json:"engine"`
+	Model          string    `json:"model"`
+	Cost           float64   `json:"cost"`
+}
+
+// Ledger is a local record of everything spent, used to enforce the per
+// title and per day budget caps across runs
+type Ledger struct {
+	FileName string
+	Entries  []LedgerEntry
+}
+
+// LoadLedger reads the ledger file; a missing file is an empty ledger
+func LoadLedger(fileName string) (*Ledger, error) {
+	toReturn := &Ledger{FileName: fileName}
+	contents, err := os.ReadFile(fileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return toReturn, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(contents, &toReturn.Entries)
+	if err != nil {
+		return nil, fmt.Errorf("parsing ledger %s: %w", fileName, err)
+	}
+	return toReturn, nil
+}
+
+// Record adds the usage of a translation of title to the ledger and saves it
+func (l *Ledger) Record(title string, usage Usage, now time.Time) error {
+	if usage.Cost == 0 {
+		return nil
+	}
+	l.Entries = append(l.Entries, LedgerEntry{
+		Time:           now,
+		Title:          title,
+		File:           usage.File,
+		TargetLanguage: usage.TargetLanguage,
+		Engine:         usage.Engine,
+		Model:          usage.Model,
+		Cost:           usage.Cost,
+	})
+	contents, err := json.MarshalIndent(l.Entries, "", "    ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(l.FileName, contents, 0644)
+}
+
+// SpentOnTitle sums the recorded cost of a title
+func (l *Ledger) SpentOnTitle(title string) float64 {
+	var toReturn float64
+	for _, entry := range l.Entries {
+		if entry.Title == title {
+			toReturn += entry.Cost
+		}
+	}
+	return toReturn
+}
+
+// SpentOn sums the recorded cost of the local calendar day of t
+func (l *Ledger) SpentOn(t time.Time) float64 {
+	var toReturn float64
+	y, m, d := t.Local().Date()
+	for _, entry := range l.Entries {
+		ey, em, ed := entry.Time.Local().Date()
+		if ey == y && em == m && ed == d {
+			toReturn += entry.Cost
+		}
+	}
+	return toReturn
+}
@@ -0,0 +1,112 @@
+package models
+
+import (
+	"errors"
+	"path"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stovak/gpt-subtitles/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/language"
+)
+
+func TestLedger(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "ledger.json")
+	ledger, err := LoadLedger(fileName)
+	assert.NoError(t, err, "LoadLedger() of a missing file")
+	assert.Empty(t, ledger.Entries)
+
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.Local)
+	assert.NoError(t, ledger.Record("pilot", Usage{TargetLanguage: "es", Cost: 1.5}, now.Add(-24*time.Hour)))
+	assert.NoError(t, ledger.Record("pilot", Usage{TargetLanguage: "fr", Cost: 2}, now))
+	assert.NoError(t, ledger.Record("episode-2", Usage{TargetLanguage: "fr", Cost: 4}, now))
+	assert.NoError(t, ledger.Record("episode-2", Usage{TargetLanguage: "de"}, now), "free usage is not recorded")
+
+	ledger, err = LoadLedger(fileName)
+	assert.NoError(t, err, "LoadLedger()")
+	assert.Len(t, ledger.Entries, 3)
+	assert.InDelta(t, 3.5, ledger.SpentOnTitle("pilot"), 0.000001)
+	assert.InDelta(t, 6, ledger.SpentOn(now), 0.000001)
+}
+
+func TestBudget_Check(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.Local)
+	ledger := &Ledger{Entries: []LedgerEntry{
+		{Time: now, Title: "pilot", Cost: 8},
+		{Time: now.Add(-48 * time.Hour), Title: "episode-2", Cost: 50},
+	}}
+	tests := []struct {
+		name     string
+		budget   Budget
+		title    string
+		runSpent float64
+		cost     float64
+		exceeded bool
+	}{
+		{name: "Check-unlimited", budget: Budget{}, title: "pilot", cost: 1000},
+		{name: "Check-run-ok", budget: Budget{Run: 5}, title: "pilot", runSpent: 2, cost: 2},
+		{name: "Check-run", budget: Budget{Run: 5}, title: "pilot", runSpent: 4, cost: 2, exceeded: true},
+		{name: "Check-title", budget: Budget{Title: 10}, title: "pilot", cost: 3, exceeded: true},
+		{name: "Check-other-title", budget: Budget{Title: 10}, title: "episode-3", cost: 3},
+		{name: "Check-day", budget: Budget{Day: 10}, title: "episode-3", cost: 3, exceeded: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.budget.Check(ledger, tt.title, tt.runSpent, tt.cost, now)
+			assert.Equalf(t, tt.exceeded, errors.Is(err, ErrBudgetExceeded), "Check() = %v", err)
+		})
+	}
+}
+
+func TestTranslationRequestBase_EstimatePasses(t *testing.T) {
+	tr := &TranslationRequestBase{
+		SubtitleFileName: path.Join(util.GetRoot(), "test-fixtures", "TestFixture1.ttml"),
+		Cmd:              &cobra.Command{},
+		SourceLanguage:   language.English,
+		TargetLanguage:   language.Spanish,
+		Reviewer:         NewReviewer("gpt-review-request.tmpl"),
+		Quality:          NewQualityEstimator("gpt-quality-request.tmpl", 70),
+	}
+	assert.NoError(t, tr.Parse(), "Parse()")
+	passes, err := tr.EstimatePasses()
+	assert.NoError(t, err, "EstimatePasses()")
+	assert.Len(t, passes, 2)
+	assert.Equal(t, "review", passes[0].Engine)
+	assert.Equal(t, 4, passes[0].Requests, "174 cues in batches of 50")
+	assert.Positive(t, passes[0].PromptTokens)
+	assert.Positive(t, passes[0].CompletionTokens)
+	assert.Equal(t, "quality", passes[1].Engine)
+	assert.Zero(t, tr.Reviewer.Usage.Requests, "nothing is sent")
+
+	hybrid, err := NewHybridTranslationRequestFromFile(tr.SubtitleFileName, "en", "es", &cobra.Command{})
+	assert.NoError(t, err, "NewHybridTranslationRequestFromFile()")
+	assert.NoError(t, hybrid.Parse(), "Parse()")
+	passes, err = hybrid.EstimatePasses()
+	assert.NoError(t, err, "EstimatePasses()")
+	assert.Len(t, passes, 1)
+	assert.Equal(t, "post-edit", passes[0].Engine)
+}
+
+func TestGPTTranslateRequest_Translate_spending(t *testing.T) {
+	newFakeOpenAI(t, fakeTranslation)
+	tr, err := NewGPTTranslationRequestFromFile(
+		path.Join(util.GetRoot(), "test-fixtures", "TestFixture1.ttml"),
+		"en", "es", &cobra.Command{})
+	assert.NoError(t, err, "NewGPTTranslationRequestFromFile()")
+	gpt := tr.(*GPTTranslationRequest)
+	gpt.Checkpoint = NewCheckpoint(t.TempDir(), gpt.SubtitleFileName, "es")
+	gpt.Spending = func() error {
+		if gpt.Usage.Requests > 0 {
+			return ErrBudgetExceeded
+		}
+		return nil
+	}
+	err = gpt.Translate()
+	assert.ErrorIs(t, err, ErrBudgetExceeded)
+	assert.ErrorContains(t, err, "100 of 174 cues translated")
+	assert.Equal(t, 1, gpt.Usage.Requests, "no batch is sent over the budget")
+	assert.FileExists(t, gpt.Checkpoint.FileName, "the finished batch is kept")
+}
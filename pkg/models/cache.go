@@ -0,0 +1,309 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+// CacheKey is everything that influences the result of an engine for one cue
+type CacheKey struct {
+	Engine         string `json:"engine"`
+	Model          string `json:"model"`
+	Template       string `json:"template"`
+	SourceLanguage string `json:"source_language"`
+	TargetLanguage string `json:"target_language"`
+	Context        string `json:"context"`
+	Source         string `json:"source"`
+}
+
+// Hash identifies the key in the cache
+func (k CacheKey) Hash() string {
+	contents, _ := json.Marshal(k)
+	sum := sha256.Sum256(contents)
+	return hex.EncodeToString(sum[:])
+}
+
+// CacheEntry is a cached engine result
+type CacheEntry struct {
+	Hash           string    `json:"hash"`
+	Engine         string    `json:"engine"`
+	Model          string    `json:"model"`
+	Template       string    `json:"template"`
+	SourceLanguage string    `json:"source_language"`
+	TargetLanguage string    `json:"target_language"`
+	Source         string    `json:"source"`
+	Translation    string    `json:"translation"`
+	Created        time.Time `json:"created"`
+}
+
+// Cache stores the result of every engine request on disk, one JSON file
+// per cue, so unchanged cues are not paid for again when a file is
+// translated a second time
+type Cache struct {
+	Dir string
+}
+
+// NewCache creates a cache in dir
+func NewCache(dir string) *Cache {
+	return &Cache{Dir: dir}
+}
+
+func (c *Cache) path(hash string) string {
+	return filepath.Join(c.Dir, hash[:2], hash+".json")
+}
+
+// Get returns the cached translation for the key
+func (c *Cache) Get(key CacheKey) (string, bool) {
+	entry, err := readCacheEntry(c.path(key.Hash()))
+	if err != nil {
+		return "", false
+	}
+	return entry.Translation, true
+}
+
+// Put stores the translation for the key
+func (c *Cache) Put(key CacheKey, translation string, now time.Time) error {
+	hash := key.Hash()
+	contents, err := json.MarshalIndent(CacheEntry{
+		Hash:           hash,
+		Engine:         key.Engine,
+		Model:          key.Model,
+		Template:       key.Template,
+		SourceLanguage: key.SourceLanguage,
+		TargetLanguage: key.TargetLanguage,
+		Source:         key.Source,
+		Translation:    translation,
+		Created:        now,
+	}, "", "    ")
+	if err != nil {
+		return err
+	}
+	fileName := c.path(hash)
+	err = os.MkdirAll(filepath.Dir(fileName), 0755)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fileName, contents, 0644)
+}
+
+// Entries returns every entry in the cache; a missing cache is empty
+func (c *Cache) Entries() ([]CacheEntry, error) {
+	var toReturn []CacheEntry
+	err := filepath.WalkDir(c.Dir, func(fileName string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil || d.IsDir() || !strings.HasSuffix(fileName, ".json") {
+			return err
+		}
+		entry, err := readCacheEntry(fileName)
+		if err != nil {
+			return err
+		}
+		toReturn = append(toReturn, entry)
+		return nil
+	})
+	return toReturn, err
+}
+
+// Prune removes the entries matching remove and returns how many were removed
+func (c *Cache) Prune(remove func(CacheEntry) bool) (int, error) {
+	entries, err := c.Entries()
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, entry := range entries {
+		if !remove(entry) {
+			continue
+		}
+		err = os.Remove(c.path(entry.Hash))
+		if err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// CacheSummary renders the number of entries and the age of the cache per
+// engine, model, prompt template and language pair as a table
+func CacheSummary(entries []CacheEntry) string {
+	type group struct {
+		row            CacheEntry
+		count          int
+		oldest, newest time.Time
+	}
+	groups := map[string]*group{}
+	for _, entry := range entries {
+		key := strings.Join([]string{entry.Engine, entry.Model, entry.Template, entry.SourceLanguage, entry.TargetLanguage}, "|")
+		g, ok := groups[key]
+		if !ok {
+			g = &group{row: entry, oldest: entry.Created, newest: entry.Created}
+			groups[key] = g
+		}
+		g.count++
+		if entry.Created.Before(g.oldest) {
+			g.oldest = entry.Created
+		}
+		if entry.Created.After(g.newest) {
+			g.newest = entry.Created
+		}
+	}
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	t := table.NewWriter()
+	t.AppendHeader(table.Row{"Engine", "Model", "Template", "Language", "Entries", "Oldest", "Newest"})
+	for _, key := range keys {
+		g := groups[key]
+		t.AppendRow(table.Row{g.row.Engine, g.row.Model, g.row.Template, fmt.Sprintf("%s => %s", g.row.SourceLanguage, g.row.TargetLanguage), g.count, g.oldest.Format(time.DateTime), g.newest.Format(time.DateTime)})
+	}
+	t.AppendFooter(table.Row{"Total", "", "", "", len(entries), "", ""})
+	return t.Render()
+}
+
+// ExportCache writes the entries as JSON or as a CSV file with the columns
+// source_language, target_language, source, target, which can be used as
+// examples file
+func ExportCache(w io.Writer, entries []CacheEntry, format string) error {
+	switch format {
+	case "json":
+		contents, err := json.MarshalIndent(entries, "", "    ")
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(contents)
+		return err
+	case "csv":
+		writer := csv.NewWriter(w)
+		err := writer.Write([]string{"source_language", "target_language", "source", "target"})
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			err = writer.Write([]string{entry.SourceLanguage, entry.TargetLanguage, entry.Source, entry.Translation})
+			if err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	}
+	return fmt.Errorf("unknown export format %q, expected csv or json", format)
+}
+
+func readCacheEntry(fileName string) (CacheEntry, error) {
+	var entry CacheEntry
+	contents, err := os.ReadFile(fileName)
+	if err != nil {
+		return entry, err
+	}
+	err = json.Unmarshal(contents, &entry)
+	return entry, err
+}
+
+// TemplateVersion identifies the text of a prompt template, so editing the template invalidates the cache
+func TemplateVersion(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:8])
+}
+
+// cacheContext is everything besides the source text that goes into every
+// prompt: the register, the show bible, the glossary and the examples. The
+// series terms are keyed per cue as they grow with every recorded episode.
+func (tr *TranslationRequestBase) cacheContext() string {
+	contents, _ := json.Marshal(struct {
+		Formality Formality
+		Bible     string
+		Glossary  []GlossaryTerm
+		Examples  []Example
+	}{tr.Formality, tr.Bible.String(), tr.Glossary, tr.Examples})
+	return TemplateVersion(string(contents))
+}
+
+// cachedResults looks up the source texts that are not in hits yet in the
+// cache, adds the cached translations to hits and returns the indexes of the
+// texts that still have to be translated
+func (tr *TranslationRequestBase) cachedResults(sourceText []string, hits map[int]string, key func(i int) CacheKey) []int {
+	var pending []int
+	cached := 0
+	for i := range sourceText {
+		if _, ok := hits[i]; ok {
+			continue
+		}
+		if tr.Cache != nil {
+			if translation, ok := tr.Cache.Get(key(i)); ok {
+				hits[i] = translation
+				cached++
+				continue
+			}
+		}
+		pending = append(pending, i)
+	}
+	if cached > 0 {
+		tr.Cmd.Printf("%d of %d cues found in the cache", cached, len(sourceText))
+	}
+	return pending
+}
+
+// cacheResults stores the translations of the source texts at the given indexes
+func (tr *TranslationRequestBase) cacheResults(indexes []int, translations []string, key func(i int) CacheKey) {
+	if tr.Cache == nil || len(indexes) != len(translations) {
+		return
+	}
+	now := time.Now()
+	for n, i := range indexes {
+		err := tr.Cache.Put(key(i), translations[n], now)
+		if err != nil {
+			tr.Cmd.PrintErrf("Error writing to the cache: %s", err)
+			return
+		}
+	}
+}
+
+// pick returns the source texts at the given indexes, "" for indexes out of range
+func pick(sourceText []string, indexes []int) []string {
+	var toReturn []string
+	for _, i := range indexes {
+		if i < 0 || i >= len(sourceText) {
+			toReturn = append(toReturn, "")
+			continue
+		}
+		toReturn = append(toReturn, sourceText[i])
+	}
+	return toReturn
+}
+
+// mergeResults puts the cache hits and the fresh results of the pending
+// indexes back in source order. When the engine returned a different number
+// of results than it was sent, the fresh results are returned as they are so
+// the mismatch is reported.
+func mergeResults(count int, hits map[int]string, pending []int, results []string) []string {
+	if len(hits) == 0 || len(results) != len(pending) {
+		return results
+	}
+	toReturn := make([]string, count)
+	for i, text := range hits {
+		toReturn[i] = text
+	}
+	for n, i := range pending {
+		toReturn[i] = results[n]
+	}
+	return toReturn
+}
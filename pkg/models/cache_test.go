@@ -0,0 +1,127 @@
+package models
+
+import (
+	"bytes"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stovak/gpt-subtitles/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/language"
+)
+
+func TestCache(t *testing.T) {
+	cache := NewCache(t.TempDir())
+	key := CacheKey{Engine: "gpt", Model: "gpt-4", SourceLanguage: "en", TargetLanguage: "es", Source: "Yes."}
+	_, ok := cache.Get(key)
+	assert.False(t, ok, "empty cache")
+
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	assert.NoError(t, cache.Put(key, "Sí.", now), "Put()")
+	assert.NoError(t, cache.Put(CacheKey{Engine: "google", Model: "nmt", SourceLanguage: "en", TargetLanguage: "es", Source: "Yes."}, "Si.", now.Add(-48*time.Hour)), "Put()")
+	translation, ok := cache.Get(key)
+	assert.True(t, ok, "cached key")
+	assert.Equal(t, "Sí.", translation)
+	key.Context = "formal"
+	_, ok = cache.Get(key)
+	assert.False(t, ok, "the context is part of the key")
+
+	entries, err := cache.Entries()
+	assert.NoError(t, err, "Entries()")
+	assert.Len(t, entries, 2)
+	assert.Contains(t, CacheSummary(entries), "en => es")
+
+	buf := new(bytes.Buffer)
+	assert.NoError(t, ExportCache(buf, entries, "csv"), "ExportCache()")
+	examples, err := readExamples(buf, language.English, language.Spanish)
+	assert.NoError(t, err, "the export is an examples file")
+	assert.Len(t, examples, 2)
+	assert.Error(t, ExportCache(buf, entries, "xlsx"), "unknown format")
+
+	removed, err := cache.Prune(func(entry CacheEntry) bool { return entry.Created.Before(now.Add(-24 * time.Hour)) })
+	assert.NoError(t, err, "Prune()")
+	assert.Equal(t, 1, removed)
+	entries, _ = cache.Entries()
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "gpt", entries[0].Engine)
+
+	entries, err = NewCache(path.Join(t.TempDir(), "missing")).Entries()
+	assert.NoError(t, err, "a missing cache is empty")
+	assert.Empty(t, entries)
+}
+
+func TestGPTTranslateRequest_Translate_cache(t *testing.T) {
+	var prompts []string
+	newFakeOpenAI(t, func(prompt string) string {
+		prompts = append(prompts, prompt)
+		return fakeTranslation(prompt)
+	})
+	cache := NewCache(t.TempDir())
+	newRequest := func() *GPTTranslationRequest {
+		tr, err := NewGPTTranslationRequestFromFile(
+			path.Join(util.GetRoot(), "test-fixtures", "TestFixture1.ttml"),
+			"en", "es", &cobra.Command{})
+		assert.NoError(t, err, "NewGPTTranslationRequestFromFile()")
+		gpt := tr.(*GPTTranslationRequest)
+		gpt.Cache = cache
+		assert.NoError(t, gpt.Parse(), "Parse()")
+		return gpt
+	}
+
+	first := newRequest()
+	assert.NoError(t, first.Translate(), "Translate()")
+	assert.Len(t, prompts, 2)
+
+	second := newRequest()
+	usage, _, err := second.DryRun()
+	assert.NoError(t, err, "DryRun()")
+	assert.Zero(t, usage.Requests, "nothing to estimate when everything is cached")
+	assert.NoError(t, second.Translate(), "Translate()")
+	assert.Len(t, prompts, 2, "a second run is served from the cache")
+	assert.Equal(t, first.GetTranslatedText(), second.GetTranslatedText())
+	assert.Zero(t, second.GetUsage().Requests)
+
+	edited := newRequest()
+	edited.Subtitles.Items[9].Lines[0].Items[0].Text = "Where is my lawyer?"
+	assert.NoError(t, edited.Translate(), "Translate()")
+	assert.Len(t, prompts, 3)
+	assert.Contains(t, prompts[2], "\n\nWhere is my lawyer?\n\n", "only the edited cue is sent")
+	translated, err := edited.GetTranslated()
+	assert.NoError(t, err, "GetTranslated()")
+	assert.Equal(t, "ES Where is my lawyer?", translated.Items[9].String())
+	assert.Equal(t, "ES Yes.", translated.Items[1].String())
+
+	formal := newRequest()
+	formal.Formality = FormalityFormal
+	assert.NoError(t, formal.Translate(), "Translate()")
+	assert.Len(t, prompts, 5, "a different register is not served from the cache")
+
+	limited := newRequest()
+	limited.Length = &LengthLimits{CharsPerSecond: 17}
+	assert.NoError(t, limited.Translate(), "Translate()")
+	assert.Len(t, prompts, 7, "different length limits are not served from the cache")
+}
+
+func TestGoogleTranslateRequest_Translate_cache(t *testing.T) {
+	requests := 0
+	client := newFakeGoogle(t, func(text string) string { return "MT " + text })
+	cache := NewCache(t.TempDir())
+	for run := 0; run < 2; run++ {
+		tr, err := NewGoogleTranslationRequestFromFile(
+			path.Join(util.GetRoot(), "test-fixtures", "TestFixture1.ttml"),
+			"en", "es", &cobra.Command{})
+		assert.NoError(t, err, "NewGoogleTranslationRequestFromFile()")
+		google := tr.(*GoogleTranslateRequest)
+		google.client = client
+		google.Cache = cache
+		assert.NoError(t, google.Parse(), "Parse()")
+		assert.NoError(t, google.Translate(), "Translate()")
+		requests += google.GetUsage().Requests
+		translated, err := google.GetTranslated()
+		assert.NoError(t, err, "GetTranslated()")
+		assert.Equal(t, "MT Where's my lawyer?", translated.Items[9].String())
+	}
+	assert.Equal(t, 1, requests, "the second run is served from the cache")
+}
@@ -0,0 +1,163 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Checkpoint persists the batches of a run as they finish, so a run that
+// is interrupted by a network error or Ctrl-C can resume where it stopped
+// instead of translating the file from scratch. The results are only valid
+// for the run they were made by: the key covers the source text, the engine
+// and the prompt.
+type Checkpoint struct {
+	FileName string         `json:"-"`
+	Key      string         `json:"key"`
+	Results  map[int]string `json:"results"`
+}
+
+// NewCheckpoint creates the checkpoint of the translation of a subtitle file
+// into the target language in dir
+func NewCheckpoint(dir string, subtitleFileName string, target string) *Checkpoint {
+	abs, err := filepath.Abs(subtitleFileName)
+	if err != nil {
+		abs = subtitleFileName
+	}
+	sum := sha256.Sum256([]byte(abs + "|" + target))
+	base := strings.TrimSuffix(filepath.Base(subtitleFileName), filepath.Ext(subtitleFileName))
+	return &Checkpoint{
+		FileName: filepath.Join(dir, fmt.Sprintf("%s_%s-%s.json", base, target, hex.EncodeToString(sum[:])[:12])),
+	}
+}
+
+// Load reads the results of the interrupted run with the same key. A missing
+// checkpoint or one with another key returns no results.
+func (c *Checkpoint) Load(key string) (map[int]string, error) {
+	c.Key = key
+	c.Results = map[int]string{}
+	contents, err := os.ReadFile(c.FileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var previous Checkpoint
+	err = json.Unmarshal(contents, &previous)
+	if err != nil {
+		return nil, fmt.Errorf("reading checkpoint %s: %w", c.FileName, err)
+	}
+	if previous.Key != key {
+		return nil, nil
+	}
+	c.Results = previous.Results
+	return previous.Results, nil
+}
+
+// Add records the results of a finished batch and writes the checkpoint. The
+// file is replaced atomically so an interruption never leaves half of it.
+func (c *Checkpoint) Add(indexes []int, results []string) error {
+	if c.Results == nil {
+		c.Results = map[int]string{}
+	}
+	for n, i := range indexes {
+		c.Results[i] = results[n]
+	}
+	contents, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(c.FileName), 0755)
+	if err != nil {
+		return err
+	}
+	temp := c.FileName + ".tmp"
+	err = os.WriteFile(temp, contents, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(temp, c.FileName)
+}
+
+// Remove deletes the checkpoint once the run is complete
+func (c *Checkpoint) Remove() error {
+	err := os.Remove(c.FileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// checkpointKey identifies a run by the engine, the model, the prompt and the
+// text sent to the engine
+func (tr *TranslationRequestBase) checkpointKey(model string, prompt string, sourceText []string) string {
+	return TemplateVersion(strings.Join([]string{
+		tr.Usage.Engine,
+		model,
+		prompt,
+		tr.cacheContext(),
+		tr.seriesContext(sourceText...),
+		tr.SourceLanguage.String(),
+		tr.TargetLanguage.String(),
+		strings.Join(sourceText, "\n"),
+	}, "|"))
+}
+
+// resumedResults starts the checkpoint of the run. With Resume, the results
+// of an interrupted run with the same key are added to hits; a checkpoint of
+// another source, engine or prompt is started over.
+func (tr *TranslationRequestBase) resumedResults(key string, hits map[int]string) error {
+	if tr.Checkpoint == nil {
+		return nil
+	}
+	if !tr.Resume {
+		tr.Checkpoint.Key = key
+		tr.Checkpoint.Results = map[int]string{}
+		return nil
+	}
+	results, err := tr.Checkpoint.Load(key)
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		tr.Cmd.Printf("No checkpoint of %s with the same source, engine and prompt, starting from scratch", tr.SubtitleFileName)
+		return nil
+	}
+	resumed := 0
+	for i, text := range results {
+		if _, ok := hits[i]; !ok {
+			hits[i] = text
+			resumed++
+		}
+	}
+	tr.Cmd.Printf("Resuming %s: %d cues were translated before the run was interrupted", tr.SubtitleFileName, resumed)
+	return nil
+}
+
+// checkpointResults records the results of a finished batch
+func (tr *TranslationRequestBase) checkpointResults(indexes []int, results []string) {
+	if tr.Checkpoint == nil || len(indexes) != len(results) {
+		return
+	}
+	err := tr.Checkpoint.Add(indexes, results)
+	if err != nil {
+		tr.Cmd.PrintErrf("Error writing the checkpoint: %s", err)
+	}
+}
+
+// finishCheckpoint removes the checkpoint of a completed run
+func (tr *TranslationRequestBase) finishCheckpoint() {
+	if tr.Checkpoint == nil {
+		return
+	}
+	err := tr.Checkpoint.Remove()
+	if err != nil {
+		tr.Cmd.PrintErrf("Error removing the checkpoint: %s", err)
+	}
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stovak/gpt-subtitles/pkg/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGPTTranslateRequest_Translate_resume(t *testing.T) {
+	dir := t.TempDir()
+	newRequest := func(resume bool) *GPTTranslationRequest {
+		tr, err := NewGPTTranslationRequestFromFile(
+			path.Join(util.GetRoot(), "test-fixtures", "TestFixture1.ttml"),
+			"en", "es", &cobra.Command{})
+		assert.NoError(t, err, "NewGPTTranslationRequestFromFile()")
+		gpt := tr.(*GPTTranslationRequest)
+		gpt.Checkpoint = NewCheckpoint(dir, gpt.SubtitleFileName, "es")
+		gpt.Resume = resume
+		return gpt
+	}
+
+	// the second batch fails
+	newFakeOpenAI(t, func(prompt string) string {
+		if strings.Contains(prompt, "And this all started because they needed a lawyer.") {
+			return strings.Repeat("ES|", 80)
+		}
+		return fakeTranslation(prompt)
+	})
+	interrupted := newRequest(false)
+	err := interrupted.Translate()
+	assert.ErrorContains(t, err, "batch starting at line 101")
+	assert.FileExists(t, interrupted.Checkpoint.FileName)
+
+	newFakeOpenAI(t, fakeTranslation)
+	resumed := newRequest(true)
+	err = resumed.Translate()
+	assert.NoError(t, err, "Translate()")
+	assert.Equal(t, 1, resumed.GetUsage().Requests, "only the failed batch is sent again")
+	translated := resumed.GetTranslatedText()
+	assert.Len(t, translated, 174, "one result per source line")
+	assert.Equal(t, "ES Yes.", translated[1])
+	assert.Equal(t, "ES And this all started because they needed a lawyer.", translated[173])
+	assert.NoFileExists(t, resumed.Checkpoint.FileName, "a complete run removes its checkpoint")
+}
+
+func TestCheckpoint_Load(t *testing.T) {
+	checkpoint := NewCheckpoint(t.TempDir(), "/videos/Episode 1.ttml", "es")
+	assert.Equal(t, "Episode 1_es-", path.Base(checkpoint.FileName)[:13])
+	results, err := checkpoint.Load("a")
+	assert.NoError(t, err, "a missing checkpoint is empty")
+	assert.Empty(t, results)
+	assert.NoError(t, checkpoint.Add([]int{0, 2}, []string{"Sí.", "No."}), "Add()")
+
+	results, err = NewCheckpoint(path.Dir(checkpoint.FileName), "/videos/Episode 1.ttml", "es").Load("a")
+	assert.NoError(t, err, "Load()")
+	assert.Equal(t, map[int]string{0: "Sí.", 2: "No."}, results)
+	results, err = NewCheckpoint(path.Dir(checkpoint.FileName), "/videos/Episode 1.ttml", "es").Load("b")
+	assert.NoError(t, err, "Load()")
+	assert.Empty(t, results, "a checkpoint of another source, engine or prompt is not resumed")
+	assert.NotEqual(t, checkpoint.FileName, NewCheckpoint(path.Dir(checkpoint.FileName), "/videos/Episode 1.ttml", "fr").FileName)
+}
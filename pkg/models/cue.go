@@ -0,0 +1,269 @@
+package models
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/asticode/go-astisub"
+)
+
+// cueText returns the text of a cue as it is translated: its lines joined
+// by spaces. The line breaks are put back by cueLines.
+func cueText(item *astisub.Item) string {
+	var lines []string
+	for _, line := range item.Lines {
+		if text := strings.TrimSpace(line.String()); text != "" {
+			lines = append(lines, text)
+		}
+	}
+	return strings.Join(lines, " ")
+}
+
+// cueLines breaks the translation of the source cue into lines like the
+// source: dialogue cues get a line per speaker, other cues as many balanced
+// lines as the source had. With length limits, a line longer than the line
+// length is broken further, up to the maximum number of lines. Text in a
+// script written without spaces, such as Chinese or Japanese, is broken
+// between its characters. The styling tags of the translation become the
+// styles of the source spans again.
+func (tr *TranslationRequestBase) cueLines(source *astisub.Item, text string) []astisub.Line {
+	words, styles := tr.styledWords(source, text)
+	plain := make([]string, len(words))
+	for i, word := range words {
+		plain[i] = word.String()
+	}
+	count := max(len(source.Lines), 1)
+	var starts []int
+	if count > 1 && isDialogue(source) {
+		starts = dialogueStarts(plain, count)
+	}
+	separator := " "
+	if starts == nil {
+		if tr.Length != nil && tr.Length.LineLength > 0 {
+			needed := (utf8.RuneCountInString(strings.Join(plain, " ")) + tr.Length.LineLength - 1) / tr.Length.LineLength
+			count = max(count, min(needed, tr.Length.Lines))
+		}
+		if isUnspaced(plain) {
+			words, plain = characters(words)
+			starts = characterBreaks(plain, count)
+			separator = ""
+		} else {
+			starts = lineBreaks(plain, count)
+		}
+	}
+	toReturn := make([]astisub.Line, len(starts))
+	for n, start := range starts {
+		end := len(words)
+		if n+1 < len(starts) {
+			end = starts[n+1]
+		}
+		// the spaces of unspaced text are kept as characters, but not at the ends of a line
+		for start < end && plain[start] == " " {
+			start++
+		}
+		for end > start && plain[end-1] == " " {
+			end--
+		}
+		toReturn[n] = astisub.Line{Items: lineItems(words[start:end], styles, separator)}
+	}
+	return toReturn
+}
+
+// isDialogue reports whether every line of the cue starts with a dash
+func isDialogue(item *astisub.Item) bool {
+	for _, line := range item.Lines {
+		if !startsWithDash(strings.TrimSpace(line.String())) {
+			return false
+		}
+	}
+	return true
+}
+
+func startsWithDash(text string) bool {
+	r, _ := utf8.DecodeRuneInString(text)
+	return strings.ContainsRune("-–—", r)
+}
+
+// dialogueStarts returns the words starting the line of every speaker of a
+// dialogue cue, or nil when the translation does not have a dash per speaker
+func dialogueStarts(words []string, speakers int) []int {
+	var toReturn []int
+	for i, word := range words {
+		if startsWithDash(word) {
+			toReturn = append(toReturn, i)
+		}
+	}
+	if len(toReturn) != speakers || toReturn[0] != 0 {
+		return nil
+	}
+	return toReturn
+}
+
+// BreakLines breaks text into at most count lines, choosing the breaks that
+// keep the longest line as short as possible. Text is broken at spaces, or
+// between its characters in a script written without spaces.
+func BreakLines(text string, count int) []string {
+	words := strings.Fields(text)
+	separator := " "
+	var starts []int
+	if isUnspaced(words) {
+		words = strings.Split(strings.Join(words, " "), "")
+		separator = ""
+		starts = characterBreaks(words, count)
+	} else {
+		starts = lineBreaks(words, count)
+	}
+	toReturn := make([]string, len(starts))
+	for n, start := range starts {
+		end := len(words)
+		if n+1 < len(starts) {
+			end = starts[n+1]
+		}
+		toReturn[n] = strings.TrimSpace(strings.Join(words[start:end], separator))
+	}
+	return toReturn
+}
+
+// lineBreaks returns the index of the first word of every line when the
+// words are broken into at most count lines with the shortest longest line
+func lineBreaks(words []string, count int) []int {
+	if count <= 1 || len(words) <= 1 {
+		return []int{0}
+	}
+	if len(words) <= count {
+		toReturn := make([]int, len(words))
+		for i := range toReturn {
+			toReturn[i] = i
+		}
+		return toReturn
+	}
+	toReturn, _ := balancedBreaks(widths(words), 1, count, func(int) bool { return true })
+	return toReturn
+}
+
+// isUnspaced reports whether the words are in a script written without
+// spaces between words, such as Chinese or Japanese
+func isUnspaced(words []string) bool {
+	for _, word := range words {
+		for _, r := range word {
+			if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// characters splits words into a word per character, keeping a space
+// between the words as a character of its own
+func characters(words []styledWord) ([]styledWord, []string) {
+	var toReturn []styledWord
+	var plain []string
+	for i, word := range words {
+		if i > 0 {
+			space := styledRun{Text: " "}
+			if previous := words[i-1][len(words[i-1])-1].Style; previous == word[0].Style {
+				space.Style = previous
+			}
+			toReturn = append(toReturn, styledWord{space})
+			plain = append(plain, " ")
+		}
+		for _, run := range word {
+			for _, r := range run.Text {
+				toReturn = append(toReturn, styledWord{{Text: string(r), Style: run.Style}})
+				plain = append(plain, string(r))
+			}
+		}
+	}
+	return toReturn, plain
+}
+
+// characterBreaks returns the index of the first character of every line
+// when text without spaces is broken into at most count lines. A line
+// does not start with closing punctuation or a combining mark, and the
+// lines are broken after punctuation or at a space when that keeps them
+// about as balanced.
+func characterBreaks(chars []string, count int) []int {
+	if count <= 1 || len(chars) <= 1 {
+		return []int{0}
+	}
+	allowed := func(i int) bool {
+		r, _ := utf8.DecodeRuneInString(chars[i])
+		return !unicode.In(r, unicode.Pe, unicode.Pf, unicode.Po, unicode.Mn, unicode.Mc, unicode.Me)
+	}
+	afterPunctuation := func(i int) bool {
+		r, _ := utf8.DecodeRuneInString(chars[i-1])
+		return allowed(i) && (unicode.IsSpace(r) || chars[i] == " " || unicode.IsPunct(r) && !unicode.In(r, unicode.Ps, unicode.Pi))
+	}
+	toReturn, longest := balancedBreaks(widths(chars), 0, count, allowed)
+	punctuated, punctuatedLongest := balancedBreaks(widths(chars), 0, count, afterPunctuation)
+	if len(punctuated) == len(toReturn) && punctuatedLongest <= longest+longest/4 {
+		return punctuated
+	}
+	return toReturn
+}
+
+// widths returns the number of characters of every word
+func widths(words []string) []int {
+	toReturn := make([]int, len(words))
+	for i, word := range words {
+		toReturn[i] = utf8.RuneCountInString(word)
+	}
+	return toReturn
+}
+
+// balancedBreaks returns the index of the first unit of every line, and the
+// width of the longest line, when units of the given widths separated by gap
+// are broken into at most count lines with the shortest longest line. A line
+// only starts at an allowed unit.
+func balancedBreaks(widths []int, gap int, count int, allowed func(i int) bool) ([]int, int) {
+	count = min(count, len(widths))
+	// width(i, j) is the width of the line of units i to j-1
+	width := func(i, j int) int {
+		toReturn := (j - i - 1) * gap
+		for _, w := range widths[i:j] {
+			toReturn += w
+		}
+		return toReturn
+	}
+	// longest[k][j] is the shortest possible longest line when the first j units are broken into k lines
+	longest := make([][]int, count+1)
+	breaks := make([][]int, count+1)
+	for k := range longest {
+		longest[k] = make([]int, len(widths)+1)
+		breaks[k] = make([]int, len(widths)+1)
+		for j := range longest[k] {
+			longest[k][j] = -1
+		}
+	}
+	for j := 1; j <= len(widths); j++ {
+		longest[1][j] = width(0, j)
+	}
+	for k := 2; k <= count; k++ {
+		for j := k; j <= len(widths); j++ {
+			for i := k - 1; i < j; i++ {
+				if longest[k-1][i] < 0 || !allowed(i) {
+					continue
+				}
+				candidate := max(longest[k-1][i], width(i, j))
+				// on a tie the earlier break keeps the last line longer, as subtitles are usually laid out
+				if longest[k][j] < 0 || candidate < longest[k][j] {
+					longest[k][j] = candidate
+					breaks[k][j] = i
+				}
+			}
+		}
+	}
+	// use fewer lines when there are not enough places to break
+	for count > 1 && longest[count][len(widths)] < 0 {
+		count--
+	}
+	toReturn := make([]int, count)
+	j := len(widths)
+	for k := count; k > 0; k-- {
+		j = breaks[k][j]
+		toReturn[k-1] = j
+	}
+	return toReturn, longest[count][len(widths)]
+}
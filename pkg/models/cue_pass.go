@@ -0,0 +1,189 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/asticode/go-astisub"
+	"github.com/ayush6624/go-chatgpt"
+	"github.com/stovak/gpt-subtitles/pkg/util"
+)
+
+// cuePassBatchSize is the number of cues sent to the LLM in a single request of a pass
+const cuePassBatchSize = 50
+
+// ReviewCue is a source cue and its translation as shown to the LLM
+type ReviewCue struct {
+	Number      int
+	Source      string
+	Translation string
+	// Limit is the maximum number of characters of the cue, 0 without length limits
+	Limit int
+}
+
+// cuePrompt is the data the template of a pass is executed with
+type cuePrompt struct {
+	*TranslationRequestBase
+	Cues []ReviewCue
+}
+
+// HasStyleTags reports whether the cues of the prompt contain styled spans
+func (p cuePrompt) HasStyleTags() bool {
+	for _, cue := range p.Cues {
+		if styleTagPattern.MatchString(cue.Source) {
+			return true
+		}
+	}
+	return false
+}
+
+// GlossaryTerms returns the glossary terms used in the cues of the prompt
+func (p cuePrompt) GlossaryTerms() []GlossaryTerm {
+	var texts []string
+	for _, cue := range p.Cues {
+		texts = append(texts, cue.Source)
+	}
+	return p.GlossaryTermsFor(texts...)
+}
+
+// cuePass is an LLM pass over the source and translated cues of a finished
+// translation, such as the review or the quality estimation. The cues are
+// sent in batches, prompted with a template from the templates directory.
+type cuePass struct {
+	RequestTemplate *template.Template
+	Model           chatgpt.ChatGPTModel
+	Usage           Usage
+	stream          *chatStream
+}
+
+func newCuePass(templateName string, engine string) cuePass {
+	return cuePass{
+		RequestTemplate: template.Must(template.ParseFiles(path.Join(util.GetRoot(), "templates", templateName))),
+		Model:           chatgpt.GPT4,
+		Usage: Usage{
+			Engine: engine,
+			Model:  string(chatgpt.GPT4),
+		},
+	}
+}
+
+// run prompts the LLM with the cues at the given indexes in batches and
+// hands each response to handle with the indexes of its batch
+func (p *cuePass) run(tr *TranslationRequestBase, translated *astisub.Subtitles, cues []int, label string, handle func(content string, batch []int) error) error {
+	if p.stream == nil {
+		var err error
+		p.stream, err = newChatStream()
+		if err != nil {
+			return err
+		}
+	}
+	for start := 0; start < len(cues); start += cuePassBatchSize {
+		batch := cues[start:min(start+cuePassBatchSize, len(cues))]
+		err := tr.checkSpending()
+		if err != nil {
+			return err
+		}
+		prompt, err := p.toPrompt(tr, translated, batch)
+		if err != nil {
+			return err
+		}
+		req := chatgpt.ChatCompletionRequest{
+			Model: p.Model,
+			Messages: []chatgpt.ChatMessage{
+				{
+					Role:    chatgpt.ChatGPTModelRoleSystem,
+					Content: prompt,
+				},
+			},
+		}
+		tr.Cmd.Printf("%s cues %d-%d of %d", label, batch[0]+1, batch[len(batch)-1]+1, len(translated.Items))
+		content, usage, err := p.stream.Complete(context.Background(), &req, nil)
+		p.Usage.Requests++
+		p.Usage.PromptTokens += usage.Prompt_Tokens
+		p.Usage.CompletionTokens += usage.Completion_Tokens
+		if err != nil {
+			return err
+		}
+		err = handle(content, batch)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// estimate renders the prompts of the pass over the cues at the given
+// indexes and estimates the tokens they would use, without contacting
+// OpenAI. The translation is assumed to be about as long as the source, so
+// the source stands in for it, and so is the answer.
+func (p *cuePass) estimate(tr *TranslationRequestBase, cues []int) (Usage, error) {
+	usage := Usage{Engine: p.Usage.Engine, Model: p.Usage.Model}
+	for start := 0; start < len(cues); start += cuePassBatchSize {
+		batch := cues[start:min(start+cuePassBatchSize, len(cues))]
+		prompt, err := p.toPrompt(tr, tr.Subtitles, batch)
+		if err != nil {
+			return usage, err
+		}
+		usage.Requests++
+		usage.PromptTokens += EstimateTokens(prompt)
+		for _, i := range batch {
+			usage.CompletionTokens += EstimateTokens(cueText(tr.Subtitles.Items[i]))
+		}
+	}
+	return usage, nil
+}
+
+func (p *cuePass) toPrompt(tr *TranslationRequestBase, translated *astisub.Subtitles, batch []int) (string, error) {
+	data := cuePrompt{TranslationRequestBase: tr}
+	for _, i := range batch {
+		source, translation := taggedCue(tr.Subtitles.Items[i], translated.Items[i])
+		cue := ReviewCue{
+			Number:      i + 1,
+			Source:      source,
+			Translation: translation,
+		}
+		if tr.Length != nil {
+			cue.Limit = tr.Length.Limit(tr.Subtitles.Items[i])
+		}
+		data.Cues = append(data.Cues, cue)
+	}
+	buf := new(strings.Builder)
+	err := p.RequestTemplate.Execute(buf, data)
+	return buf.String(), err
+}
+
+// taggedCue returns the text of a source cue and of its translation with
+// their styled spans tagged alike, so the LLM can keep the tags in its edits
+func taggedCue(source *astisub.Item, translated *astisub.Item) (string, string) {
+	sourceText, styles := taggedText(source, nil)
+	translatedText, _ := taggedText(translated, styles)
+	return sourceText, translatedText
+}
+
+// allCues returns the indexes of every cue both subtitles have
+func allCues(tr *TranslationRequestBase, translated *astisub.Subtitles) []int {
+	var toReturn []int
+	for i := range min(len(tr.Subtitles.Items), len(translated.Items)) {
+		toReturn = append(toReturn, i)
+	}
+	return toReturn
+}
+
+// checkCueNumber returns an error if the cue number of an answer of the LLM is not one of the cues of the batch
+func checkCueNumber(answer string, cue int, batch []int) error {
+	if !slices.Contains(batch, cue-1) {
+		return fmt.Errorf("%s for cue %d, which is not in cues %d-%d", answer, cue, batch[0]+1, batch[len(batch)-1]+1)
+	}
+	return nil
+}
+
+// unfenceJSON strips the markdown code block LLMs like to wrap JSON in
+func unfenceJSON(content string) string {
+	content = strings.TrimSpace(content)
+	content = strings.TrimPrefix(content, "```json")
+	return strings.Trim(content, "`\n ")
+}
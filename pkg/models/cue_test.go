@@ -0,0 +1,118 @@
+package models
+
+import (
+	"path"
+	"testing"
+
+	"github.com/asticode/go-astisub"
+	"github.com/spf13/cobra"
+	"github.com/stovak/gpt-subtitles/pkg/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBreakLines(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		count int
+		want  []string
+	}{
+		{
+			name:  "BreakLines-one",
+			text:  "En camino.  Podemos esperar.",
+			count: 1,
+			want:  []string{"En camino. Podemos esperar."},
+		},
+		{
+			name:  "BreakLines-balanced",
+			text:  "En camino. Podemos esperar hasta que llegue.",
+			count: 2,
+			want:  []string{"En camino. Podemos", "esperar hasta que llegue."},
+		},
+		{
+			name:  "BreakLines-three",
+			text:  "uno dos tres cuatro cinco seis",
+			count: 3,
+			want:  []string{"uno dos", "tres cuatro", "cinco seis"},
+		},
+		{
+			name:  "BreakLines-few-words",
+			text:  "Sí.",
+			count: 2,
+			want:  []string{"Sí."},
+		},
+		{
+			name:  "BreakLines-chinese",
+			text:  "我的律师在哪里，我现在就要见他。",
+			count: 2,
+			want:  []string{"我的律师在哪里，", "我现在就要见他。"},
+		},
+		{
+			name:  "BreakLines-chinese-punctuation",
+			text:  "我明白你的意思，但是不行。",
+			count: 2,
+			want:  []string{"我明白你的意思，", "但是不行。"},
+		},
+		{
+			name:  "BreakLines-japanese",
+			text:  "弁護士はどこにいるんだ",
+			count: 2,
+			want:  []string{"弁護士はど", "こにいるんだ"},
+		},
+		{
+			name:  "BreakLines-chinese-space",
+			text:  "他在哪里？ 他马上就到。",
+			count: 2,
+			want:  []string{"他在哪里？", "他马上就到。"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, BreakLines(tt.text, tt.count))
+		})
+	}
+}
+
+func TestTranslationRequestBase_cueLines(t *testing.T) {
+	lines := func(texts ...string) *astisub.Item {
+		item := &astisub.Item{}
+		for _, text := range texts {
+			item.Lines = append(item.Lines, astisub.Line{Items: []astisub.LineItem{{Text: text}}})
+		}
+		return item
+	}
+	tr := &TranslationRequestBase{}
+	assert.Equal(t, "- Where's my lawyer? - On the way.", cueText(lines("- Where's my lawyer?", "- On the way.")))
+	assert.Equal(t, lines("- ¿Y mi abogado?", "- En camino."), &astisub.Item{Lines: tr.cueLines(lines("- Where's my lawyer?", "- On the way."), "- ¿Y mi abogado? - En camino.")}, "a line per speaker")
+	assert.Equal(t, lines("¿Y mi abogado?", "En camino."), &astisub.Item{Lines: tr.cueLines(lines("Where's my lawyer?", "On the way."), "¿Y mi abogado? En camino.")}, "as many lines as the source")
+	assert.Equal(t, lines("¿Dónde está mi abogado? Ya viene en camino."), &astisub.Item{Lines: tr.cueLines(lines("Where's my lawyer? On the way."), "¿Dónde está mi abogado? Ya viene en camino.")})
+	assert.Equal(t, lines("我的律师在哪里，", "我现在就要见他。"), &astisub.Item{Lines: tr.cueLines(lines("Where's my lawyer?", "I want to see him now."), "我的律师在哪里，我现在就要见他。")}, "unspaced text is broken between characters")
+	tr.Length = &LengthLimits{LineLength: 25, Lines: 2}
+	assert.Equal(t, lines("¿Dónde está mi abogado?", "Ya viene en camino."), &astisub.Item{Lines: tr.cueLines(lines("Where's my lawyer? On the way."), "¿Dónde está mi abogado? Ya viene en camino.")}, "lines longer than the line length are broken")
+}
+
+func TestGPTTranslateRequest_GetTranslated_lines(t *testing.T) {
+	newFakeOpenAI(t, fakeTranslation)
+	tr, err := NewGPTTranslationRequestFromFile(
+		path.Join(util.GetRoot(), "test-fixtures", "TestFixture1.ttml"),
+		"en", "es", &cobra.Command{})
+	assert.NoError(t, err, "NewGPTTranslationRequestFromFile()")
+	gpt := tr.(*GPTTranslationRequest)
+	gpt.Subtitles.Items[10].Lines = []astisub.Line{
+		{Items: []astisub.LineItem{{Text: "On the way."}}},
+		{Items: []astisub.LineItem{{Text: "We can wait until she gets here."}}},
+	}
+	sourceText := tr.GetSourceText()
+	assert.Len(t, sourceText, 174, "one entry per cue")
+	assert.Equal(t, "On the way. We can wait until she gets here.", sourceText[10])
+
+	err = tr.Translate()
+	assert.NoError(t, err, "Translate()")
+	translated, err := tr.GetTranslated()
+	assert.NoError(t, err, "GetTranslated()")
+	assert.Len(t, translated.Items, 174)
+	assert.Len(t, translated.Items[10].Lines, 2, "the cue keeps its two lines")
+	assert.Equal(t, "ES On the way. We can", translated.Items[10].Lines[0].String())
+	assert.Equal(t, "wait until she gets here.", translated.Items[10].Lines[1].String())
+	assert.Equal(t, "ES Legally.", cueText(translated.Items[11]))
+}
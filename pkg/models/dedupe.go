@@ -0,0 +1,96 @@
+package models
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/stovak/gpt-subtitles/pkg/util"
+)
+
+// Dedupe sends every distinct source text to the engine once and copies its
+// translation to the repeats. Short lines like "Yes." or "What?" recur
+// constantly and only need to be paid for once.
+type Dedupe struct {
+	// Context only merges repeats whose neighbouring cues are identical as
+	// well, so a line that reads differently in another scene gets its own
+	// translation
+	Context bool `mapstructure:"context"`
+	// Individual lists lines that depend on who says them to whom and are
+	// always translated individually, e.g. "Are you ready?"
+	Individual []string `mapstructure:"individual"`
+}
+
+// key identifies the text at index i for deduplication: its text with
+// normalized whitespace and, in context mode, its neighbours
+func (d *Dedupe) key(sourceText []string, i int) string {
+	text := func(i int) string {
+		return strings.Join(strings.Fields(pick(sourceText, []int{i})[0]), " ")
+	}
+	if !d.Context {
+		return text(i)
+	}
+	return strings.Join([]string{text(i - 1), text(i), text(i + 1)}, "|")
+}
+
+// individual reports whether text is always translated individually
+func (d *Dedupe) individual(text string) bool {
+	normalized := util.NormalizeText(text)
+	for _, line := range d.Individual {
+		if util.NormalizeText(line) == normalized {
+			return true
+		}
+	}
+	return false
+}
+
+// dedupe returns the pending indexes whose text is sent to the engine and the
+// repeats among the pending indexes, mapped to the index translated for them
+func (tr *TranslationRequestBase) dedupe(sourceText []string, pending []int) ([]int, map[int]int) {
+	repeats := map[int]int{}
+	if tr.Dedupe == nil {
+		return pending, repeats
+	}
+	var unique []int
+	first := map[string]int{}
+	saved := 0
+	for _, i := range pending {
+		if tr.Dedupe.individual(sourceText[i]) {
+			unique = append(unique, i)
+			continue
+		}
+		key := tr.Dedupe.key(sourceText, i)
+		if original, ok := first[key]; ok {
+			repeats[i] = original
+			saved += utf8.RuneCountInString(sourceText[i])
+			continue
+		}
+		first[key] = i
+		unique = append(unique, i)
+	}
+	if len(repeats) > 0 {
+		tr.Cmd.Printf("%d of %d cues repeat an earlier cue and are translated once, saving %d characters", len(repeats), len(pending), saved)
+	}
+	return unique, repeats
+}
+
+// fanOut copies the results of the unique indexes to their repeats and
+// returns the results of all pending indexes in order. When the engine
+// returned a different number of results than it was sent, the results are
+// returned as they are so the mismatch is reported.
+func fanOut(pending []int, unique []int, repeats map[int]int, results []string) []string {
+	if len(repeats) == 0 || len(results) != len(unique) {
+		return results
+	}
+	translated := map[int]string{}
+	for n, i := range unique {
+		translated[i] = results[n]
+	}
+	toReturn := make([]string, 0, len(pending))
+	for _, i := range pending {
+		if original, ok := repeats[i]; ok {
+			i = original
+		}
+		toReturn = append(toReturn, translated[i])
+	}
+	return toReturn
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"path"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stovak/gpt-subtitles/pkg/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTranslationRequestBase_dedupe(t *testing.T) {
+	sourceText := []string{"Yes.", "Where's my lawyer?", "Yes.", "What?", " Yes. ", "Where's my lawyer?", "What?"}
+	tests := []struct {
+		name        string
+		dedupe      *Dedupe
+		wantUnique  []int
+		wantRepeats map[int]int
+	}{
+		{
+			name:        "Dedupe-disabled",
+			dedupe:      nil,
+			wantUnique:  []int{0, 1, 2, 3, 4, 5, 6},
+			wantRepeats: map[int]int{},
+		},
+		{
+			name:        "Dedupe-text",
+			dedupe:      &Dedupe{},
+			wantUnique:  []int{0, 1, 3},
+			wantRepeats: map[int]int{2: 0, 4: 0, 5: 1, 6: 3},
+		},
+		{
+			name:        "Dedupe-context",
+			dedupe:      &Dedupe{Context: true},
+			wantUnique:  []int{0, 1, 2, 3, 4, 5, 6},
+			wantRepeats: map[int]int{},
+		},
+		{
+			name:        "Dedupe-individual",
+			dedupe:      &Dedupe{Individual: []string{"what"}},
+			wantUnique:  []int{0, 1, 3, 6},
+			wantRepeats: map[int]int{2: 0, 4: 0, 5: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &TranslationRequestBase{Cmd: &cobra.Command{}, Dedupe: tt.dedupe}
+			pending := cueIndexes(0, len(sourceText))
+			unique, repeats := tr.dedupe(sourceText, pending)
+			assert.Equal(t, tt.wantUnique, unique, "unique")
+			assert.Equal(t, tt.wantRepeats, repeats, "repeats")
+			results := fanOut(pending, unique, repeats, pick(sourceText, unique))
+			assert.Equal(t, sourceText[6], results[6], "the repeats get the translation of their original")
+			assert.Len(t, results, len(sourceText))
+		})
+	}
+	assert.Equal(t, []string{"a"}, fanOut([]int{0, 1, 2}, []int{0, 1}, map[int]int{2: 0}, []string{"a"}), "too few results are left for the count check")
+}
+
+func TestGPTTranslateRequest_Translate_dedupe(t *testing.T) {
+	newFakeOpenAI(t, fakeTranslation)
+	tr, err := NewGPTTranslationRequestFromFile(
+		path.Join(util.GetRoot(), "test-fixtures", "TestFixture1.ttml"),
+		"en", "es", &cobra.Command{})
+	assert.NoError(t, err, "NewGPTTranslationRequestFromFile()")
+	gpt := tr.(*GPTTranslationRequest)
+	for _, item := range gpt.Subtitles.Items[100:] {
+		item.Lines[0].Items = item.Lines[0].Items[:1]
+		item.Lines[0].Items[0].Text = "Yes."
+	}
+	gpt.Dedupe = &Dedupe{}
+	err = tr.Translate()
+	assert.NoError(t, err, "Translate()")
+	translated := tr.GetTranslatedText()
+	assert.Len(t, translated, len(tr.GetSourceText()), "one result per source line")
+	assert.Equal(t, "ES Legally.", translated[11])
+	assert.Equal(t, "ES Yes.", translated[173], "repeats get the translation of the first cue")
+	usage := tr.GetUsage()
+	assert.Equal(t, 1, usage.Requests, "the repeats leave a single batch")
+	assert.Equal(t, 74, usage.Deduplicated)
+}
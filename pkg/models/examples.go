@@ -0,0 +1,97 @@
+package models
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/asticode/go-astisub"
+	"golang.org/x/text/language"
+)
+
+// Example is a curated source/target cue pair that is injected into LLM
+// prompts as a few-shot demonstration of the expected style.
+type Example struct {
+	Source string
+	Target string
+}
+
+// LoadExamplesFile reads few-shot examples from a CSV file with the columns
+// source_language, target_language, source, target. Only rows matching the
+// given language pair are returned; a header row is skipped if present.
+func LoadExamplesFile(fileName string, source language.Tag, target language.Tag) ([]Example, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readExamples(f, source, target)
+}
+
+func readExamples(r io.Reader, source language.Tag, target language.Tag) ([]Example, error) {
+	var toReturn []Example
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 4
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	for i, record := range records {
+		if i == 0 && strings.EqualFold(record[0], "source_language") {
+			continue
+		}
+		if !sameBaseLanguage(record[0], source) || !sameBaseLanguage(record[1], target) {
+			continue
+		}
+		toReturn = append(toReturn, Example{
+			Source: strings.TrimSpace(record[2]),
+			Target: strings.TrimSpace(record[3]),
+		})
+	}
+	return toReturn, nil
+}
+
+// LoadExamplesFromTranslation pairs the cues of a previously approved
+// translation with the cues of its source file. Both files must contain the
+// same number of cues.
+func LoadExamplesFromTranslation(sourceFileName string, targetFileName string) ([]Example, error) {
+	sourceSubs, err := astisub.OpenFile(sourceFileName)
+	if err != nil {
+		return nil, err
+	}
+	targetSubs, err := astisub.OpenFile(targetFileName)
+	if err != nil {
+		return nil, err
+	}
+	if len(sourceSubs.Items) != len(targetSubs.Items) {
+		return nil, fmt.Errorf("number of cues in %s (%d) does not match number of cues in %s (%d)", targetFileName, len(targetSubs.Items), sourceFileName, len(sourceSubs.Items))
+	}
+	var toReturn []Example
+	for i, item := range sourceSubs.Items {
+		source := strings.TrimSpace(cueText(item))
+		target := strings.TrimSpace(cueText(targetSubs.Items[i]))
+		if source == "" || target == "" {
+			continue
+		}
+		toReturn = append(toReturn, Example{
+			Source: source,
+			Target: target,
+		})
+	}
+	return toReturn, nil
+}
+
+// sameBaseLanguage reports whether a language code read from a file refers
+// to the same base language as tag, so "es-MX" rows apply to "es" requests.
+func sameBaseLanguage(code string, tag language.Tag) bool {
+	parsed, err := language.Parse(strings.TrimSpace(code))
+	if err != nil {
+		return false
+	}
+	a, _ := parsed.Base()
+	b, _ := tag.Base()
+	return a == b
+}
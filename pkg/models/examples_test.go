@@ -0,0 +1,65 @@
+package models
+
+import (
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stovak/gpt-subtitles/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/language"
+)
+
+func TestReadExamples(t *testing.T) {
+	csv := `source_language,target_language,source,target
+en,es,How are you?,¿Cómo estás?
+en,es-MX,"Yes, sir.","Sí, señor."
+en,fr,How are you?,Comment vas-tu ?
+`
+	tests := []struct {
+		name   string
+		source string
+		target string
+		want   []Example
+	}{
+		{
+			name:   "Examples-es",
+			source: "en",
+			target: "es",
+			want: []Example{
+				{Source: "How are you?", Target: "¿Cómo estás?"},
+				{Source: "Yes, sir.", Target: "Sí, señor."},
+			},
+		},
+		{
+			name:   "Examples-fr",
+			source: "en",
+			target: "fr",
+			want: []Example{
+				{Source: "How are you?", Target: "Comment vas-tu ?"},
+			},
+		},
+		{
+			name:   "Examples-ko",
+			source: "en",
+			target: "ko",
+			want:   nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readExamples(strings.NewReader(csv), language.MustParse(tt.source), language.MustParse(tt.target))
+			assert.NoError(t, err, "readExamples()")
+			assert.Equalf(t, tt.want, got, "readExamples(%s, %s)", tt.source, tt.target)
+		})
+	}
+}
+
+func TestLoadExamplesFromTranslation(t *testing.T) {
+	fileName := path.Join(util.GetRoot(), "test-fixtures", "TestFixture1.ttml")
+	got, err := LoadExamplesFromTranslation(fileName, fileName)
+	assert.NoError(t, err, "LoadExamplesFromTranslation()")
+	assert.NotZerof(t, len(got), "no examples loaded")
+	assert.Equalf(t, "Yes.", got[1].Source, "unexpected source for the second cue")
+	assert.Equalf(t, got[1].Source, got[1].Target, "source and target should pair the same cue")
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// Formality is the register a translation should address the audience in
+type Formality string
+
+const (
+	FormalityAuto     Formality = "auto"
+	FormalityFormal   Formality = "formal"
+	FormalityInformal Formality = "informal"
+)
+
+// formalityPronouns holds the formal and informal forms of address for
+// languages where the distinction is grammatical
+var formalityPronouns = map[string][2]string{
+	"es": {"usted/ustedes", "tú/vosotros"},
+	"fr": {"vous", "tu"},
+	"de": {"Sie", "du"},
+	"pt": {"o senhor/a senhora", "você/tu"},
+	"ru": {"вы", "ты"},
+	"hi": {"आप", "तुम"},
+	"zh": {"您", "你"},
+	"ja": {"敬語 (keigo)", "タメ口 (casual speech)"},
+	"ko": {"존댓말 (jondaetmal)", "반말 (banmal)"},
+}
+
+// ParseFormality parses formal, informal or auto; an empty string is auto
+func ParseFormality(s string) (Formality, error) {
+	switch f := Formality(strings.ToLower(strings.TrimSpace(s))); f {
+	case "", FormalityAuto:
+		return FormalityAuto, nil
+	case FormalityFormal, FormalityInformal:
+		return f, nil
+	default:
+		return FormalityAuto, fmt.Errorf("unknown formality %q, expected formal, informal or auto", s)
+	}
+}
+
+// Instruction returns the prompt instruction that steers an LLM towards the
+// register for the target language. It is empty for auto.
+func (f Formality) Instruction(target language.Tag) string {
+	if f == FormalityAuto || f == "" {
+		return ""
+	}
+	base, _ := target.Base()
+	if pronouns, ok := formalityPronouns[base.String()]; ok {
+		if f == FormalityFormal {
+			return fmt.Sprintf("Use the formal register throughout: address people with %s, never %s.", pronouns[0], pronouns[1])
+		}
+		return fmt.Sprintf("Use the informal register throughout: address people with %s, never %s.", pronouns[1], pronouns[0])
+	}
+	return fmt.Sprintf("Use a consistently %s register throughout.", f)
+}
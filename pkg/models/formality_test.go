@@ -0,0 +1,53 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/language"
+)
+
+func TestParseFormality(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    Formality
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{name: "Formality-empty", value: "", want: FormalityAuto, wantErr: assert.NoError},
+		{name: "Formality-formal", value: "Formal", want: FormalityFormal, wantErr: assert.NoError},
+		{name: "Formality-informal", value: "informal", want: FormalityInformal, wantErr: assert.NoError},
+		{name: "Formality-unknown", value: "polite", want: FormalityAuto, wantErr: assert.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseFormality(tt.value)
+			tt.wantErr(t, err, "ParseFormality(%s)", tt.value)
+			assert.Equalf(t, tt.want, got, "ParseFormality(%s)", tt.value)
+		})
+	}
+}
+
+func TestFormality_Instruction(t *testing.T) {
+	tests := []struct {
+		name      string
+		formality Formality
+		target    string
+		contains  string
+	}{
+		{name: "Instruction-auto", formality: FormalityAuto, target: "es", contains: ""},
+		{name: "Instruction-es-formal", formality: FormalityFormal, target: "es", contains: "usted/ustedes, never tú"},
+		{name: "Instruction-fr-informal", formality: FormalityInformal, target: "fr-CA", contains: "with tu, never vous"},
+		{name: "Instruction-it-formal", formality: FormalityFormal, target: "it", contains: "formal register"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.formality.Instruction(language.MustParse(tt.target))
+			if tt.contains == "" {
+				assert.Empty(t, got)
+				return
+			}
+			assert.Contains(t, got, tt.contains)
+		})
+	}
+}
@@ -0,0 +1,245 @@
+package models
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/asticode/go-astisub"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"golang.org/x/text/language"
+)
+
+// GlossaryTerm is a source term and the rendering it must have in the target
+// language. Terms marked DoNotTranslate must appear unchanged in the output.
+type GlossaryTerm struct {
+	Source         string
+	Target         string
+	DoNotTranslate bool
+	pattern        *regexp.Regexp
+}
+
+// GlossaryViolation is a cue whose source contains a glossary term but whose
+// translation does not use the required rendering
+type GlossaryViolation struct {
+	Index      int
+	Term       GlossaryTerm
+	Source     string
+	Translated string
+}
+
+// LoadGlossaryFile reads the glossary terms for a language pair from a CSV or
+// TBX file. CSV files have a header row naming the columns: "source", an
+// optional "dnt" column (yes/true/1 marks a do-not-translate term) and one
+// column per target language code. In TBX files a term entry is marked
+// do-not-translate with <termNote type="doNotTranslate">yes</termNote>.
+func LoadGlossaryFile(fileName string, source language.Tag, target language.Tag) ([]GlossaryTerm, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".tbx", ".xml":
+		return readGlossaryTBX(f, source, target)
+	default:
+		return readGlossaryCSV(f, target)
+	}
+}
+
+func readGlossaryCSV(r io.Reader, target language.Tag) ([]GlossaryTerm, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	sourceColumn, dntColumn, targetColumn := -1, -1, -1
+	for i, name := range records[0] {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch {
+		case name == "source":
+			sourceColumn = i
+		case name == "dnt" || name == "do_not_translate":
+			dntColumn = i
+		case targetColumn == -1 && sameBaseLanguage(name, target):
+			targetColumn = i
+		}
+	}
+	if sourceColumn == -1 {
+		return nil, fmt.Errorf("glossary has no source column")
+	}
+	var toReturn []GlossaryTerm
+	for _, record := range records[1:] {
+		term := GlossaryTerm{
+			Source: strings.TrimSpace(record[sourceColumn]),
+		}
+		if dntColumn != -1 {
+			term.DoNotTranslate = isTruthy(record[dntColumn])
+		}
+		if targetColumn != -1 {
+			term.Target = strings.TrimSpace(record[targetColumn])
+		}
+		if term.Source == "" {
+			continue
+		}
+		if term.DoNotTranslate {
+			term.Target = term.Source
+		}
+		if term.Target == "" {
+			continue
+		}
+		toReturn = append(toReturn, term)
+	}
+	return toReturn, nil
+}
+
+type tbxDocument struct {
+	Entries []struct {
+		Notes    []tbxNote `xml:"termNote"`
+		LangSets []struct {
+			Lang  string    `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+			Notes []tbxNote `xml:"termNote"`
+			Terms []string  `xml:"tig>term"`
+			NTigs []string  `xml:"ntig>termGrp>term"`
+		} `xml:"langSet"`
+	} `xml:"text>body>termEntry"`
+}
+
+type tbxNote struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+func readGlossaryTBX(r io.Reader, source language.Tag, target language.Tag) ([]GlossaryTerm, error) {
+	var doc tbxDocument
+	err := xml.NewDecoder(r).Decode(&doc)
+	if err != nil {
+		return nil, err
+	}
+	var toReturn []GlossaryTerm
+	for _, entry := range doc.Entries {
+		var term GlossaryTerm
+		notes := entry.Notes
+		for _, langSet := range entry.LangSets {
+			terms := append(langSet.Terms, langSet.NTigs...)
+			if len(terms) == 0 {
+				continue
+			}
+			switch {
+			case term.Source == "" && sameBaseLanguage(langSet.Lang, source):
+				term.Source = strings.TrimSpace(terms[0])
+				notes = append(notes, langSet.Notes...)
+			case term.Target == "" && sameBaseLanguage(langSet.Lang, target):
+				term.Target = strings.TrimSpace(terms[0])
+			}
+		}
+		for _, note := range notes {
+			if note.Type == "doNotTranslate" && isTruthy(note.Value) {
+				term.DoNotTranslate = true
+			}
+		}
+		if term.DoNotTranslate {
+			term.Target = term.Source
+		}
+		if term.Source == "" || term.Target == "" {
+			continue
+		}
+		toReturn = append(toReturn, term)
+	}
+	return toReturn, nil
+}
+
+func isTruthy(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "yes", "y", "true", "1", "x":
+		return true
+	}
+	return false
+}
+
+// Matches reports whether the term occurs as a whole word in text, ignoring case
+func (g *GlossaryTerm) Matches(text string) bool {
+	if g.pattern == nil {
+		g.pattern = regexp.MustCompile(`(?i)(^|\P{L})` + regexp.QuoteMeta(g.Source) + `($|\P{L})`)
+	}
+	return g.pattern.MatchString(text)
+}
+
+// RenderedIn reports whether the required rendering of the term occurs in text
+func (g *GlossaryTerm) RenderedIn(text string) bool {
+	if g.DoNotTranslate {
+		return strings.Contains(text, g.Target)
+	}
+	return strings.Contains(strings.ToLower(text), strings.ToLower(g.Target))
+}
+
+// GlossaryTermsFor returns the glossary terms that occur in any of the texts
+func (tr *TranslationRequestBase) GlossaryTermsFor(texts ...string) []GlossaryTerm {
+	var toReturn []GlossaryTerm
+	for i := range tr.Glossary {
+		for _, text := range texts {
+			if tr.Glossary[i].Matches(text) {
+				toReturn = append(toReturn, tr.Glossary[i])
+				break
+			}
+		}
+	}
+	return toReturn
+}
+
+// VerifyGlossary checks every translated cue whose source contains a glossary
+// term for the term's required rendering
+func (tr *TranslationRequestBase) VerifyGlossary(translated *astisub.Subtitles) []GlossaryViolation {
+	return tr.verifyTerms(tr.Glossary, translated)
+}
+
+// verifyTerms returns the cues whose source contains one of the terms but
+// whose translation does not use its rendering
+func (tr *TranslationRequestBase) verifyTerms(terms []GlossaryTerm, translated *astisub.Subtitles) []GlossaryViolation {
+	var toReturn []GlossaryViolation
+	if len(terms) == 0 {
+		return nil
+	}
+	for i, item := range tr.Subtitles.Items {
+		if i >= len(translated.Items) {
+			break
+		}
+		source := cueText(item)
+		target := cueText(translated.Items[i])
+		for j := range terms {
+			term := &terms[j]
+			if term.Matches(source) && !term.RenderedIn(target) {
+				toReturn = append(toReturn, GlossaryViolation{
+					Index:      i,
+					Term:       *term,
+					Source:     source,
+					Translated: target,
+				})
+			}
+		}
+	}
+	return toReturn
+}
+
+// WriteGlossaryReport writes a table of glossary violations next to the source file
+func (tr *TranslationRequestBase) WriteGlossaryReport(violations []GlossaryViolation) error {
+	return tr.writeViolations("glossary", violations)
+}
+
+func (tr *TranslationRequestBase) writeViolations(variant string, violations []GlossaryViolation) error {
+	t := table.NewWriter()
+	t.AppendHeader(table.Row{"#", "Term", "Required", "Source", "Translated"})
+	for _, v := range violations {
+		t.AppendRow(table.Row{v.Index + 1, v.Term.Source, v.Term.Target, v.Source, v.Translated})
+	}
+	return tr.WriteReport(fmt.Sprintf("%s-%s", tr.TargetLanguage, variant), t.Render())
+}
@@ -0,0 +1,101 @@
+package models
+
+import (
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/asticode/go-astisub"
+	"github.com/spf13/cobra"
+	"github.com/stovak/gpt-subtitles/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/language"
+)
+
+func TestReadGlossaryCSV(t *testing.T) {
+	csv := `source,dnt,es,fr
+Detective,,Detective,Inspecteur
+Cronkite,yes,,
+First Amendment,,Primera Enmienda,
+`
+	tests := []struct {
+		name   string
+		target string
+		want   []GlossaryTerm
+	}{
+		{
+			name:   "Glossary-es",
+			target: "es",
+			want: []GlossaryTerm{
+				{Source: "Detective", Target: "Detective"},
+				{Source: "Cronkite", Target: "Cronkite", DoNotTranslate: true},
+				{Source: "First Amendment", Target: "Primera Enmienda"},
+			},
+		},
+		{
+			name:   "Glossary-fr",
+			target: "fr",
+			want: []GlossaryTerm{
+				{Source: "Detective", Target: "Inspecteur"},
+				{Source: "Cronkite", Target: "Cronkite", DoNotTranslate: true},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readGlossaryCSV(strings.NewReader(csv), language.MustParse(tt.target))
+			assert.NoError(t, err, "readGlossaryCSV()")
+			assert.Equalf(t, tt.want, got, "readGlossaryCSV(%s)", tt.target)
+		})
+	}
+}
+
+func TestReadGlossaryTBX(t *testing.T) {
+	tbx := `<?xml version="1.0" encoding="UTF-8"?>
+<martif type="TBX" xml:lang="en">
+  <text>
+    <body>
+      <termEntry id="1">
+        <langSet xml:lang="en"><tig><term>Detective</term></tig></langSet>
+        <langSet xml:lang="es"><tig><term>Detective</term></tig></langSet>
+        <langSet xml:lang="fr"><tig><term>Inspecteur</term></tig></langSet>
+      </termEntry>
+      <termEntry id="2">
+        <termNote type="doNotTranslate">yes</termNote>
+        <langSet xml:lang="en"><ntig><termGrp><term>Rolling Stone</term></termGrp></ntig></langSet>
+      </termEntry>
+    </body>
+  </text>
+</martif>`
+	got, err := readGlossaryTBX(strings.NewReader(tbx), language.English, language.French)
+	assert.NoError(t, err, "readGlossaryTBX()")
+	assert.Equal(t, []GlossaryTerm{
+		{Source: "Detective", Target: "Inspecteur"},
+		{Source: "Rolling Stone", Target: "Rolling Stone", DoNotTranslate: true},
+	}, got)
+}
+
+func TestTranslationRequestBase_VerifyGlossary(t *testing.T) {
+	tr := &TranslationRequestBase{
+		SubtitleFileName: path.Join(util.GetRoot(), "test-fixtures", "TestFixture1.ttml"),
+		Cmd:              &cobra.Command{},
+		Glossary: []GlossaryTerm{
+			{Source: "Detective", Target: "Detective"},
+			{Source: "Cronkite", Target: "Cronkite", DoNotTranslate: true},
+		},
+	}
+	assert.NoError(t, tr.Parse(), "Parse()")
+	translated := astisub.NewSubtitles()
+	for _, item := range tr.Subtitles.Items {
+		text := item.String()
+		text = strings.ReplaceAll(text, "Detective", "Inspector")
+		translated.Items = append(translated.Items, &astisub.Item{
+			Lines: []astisub.Line{{Items: []astisub.LineItem{{Text: text}}}},
+		})
+	}
+	violations := tr.VerifyGlossary(translated)
+	assert.Len(t, violations, 1, "expected the renamed detective to be reported")
+	assert.Equal(t, 2, violations[0].Index)
+	assert.Equal(t, "Detective", violations[0].Term.Source)
+	assert.Len(t, tr.GlossaryTermsFor("So you do or you don't work there, Mr. Dan. F*****g Cronkite."), 1)
+}
@@ -4,10 +4,15 @@ import (
 	"context"
 	"fmt"
 	"github.com/spf13/cobra"
+	"html"
 	"log"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"cloud.google.com/go/translate"
 	"github.com/asticode/go-astisub"
@@ -21,7 +26,7 @@ type GoogleTranslateRequest struct {
 }
 
 func NewGoogleTranslationRequestFromFile(fileName string, sourceLanguage string, destinationLanguage string, cmd *cobra.Command) (TranslationRequest, error) {
-	subs, err := astisub.OpenFile(fileName)
+	subs, err := OpenSubtitles(fileName)
 	if err != nil {
 		return &GoogleTranslateRequest{}, err
 	}
@@ -34,20 +39,150 @@ func NewGoogleTranslationRequestFromFile(fileName string, sourceLanguage string,
 	}
 	toReturn.ParseSourceTarget(sourceLanguage, destinationLanguage)
 	toReturn.Extension = filepath.Ext(fileName)
+	toReturn.Usage.Engine = "google"
+	toReturn.Usage.Model = "nmt"
 	return &toReturn, nil
 }
 
 func (tr *GoogleTranslateRequest) Translate() error {
-	var err error
 	tr.Cmd.Printf("Translating %s to %s", tr.SourceLanguage, tr.TargetLanguage)
 	sourceText := tr.GetSourceText()
 	tr.Cmd.Printf("Source Text: %#v", sourceText)
-	tr.results, err = tr.getClient().Translate(context.Background(), tr.GetSourceText(), tr.TargetLanguage, &translate.Options{
-		Source: tr.SourceLanguage,
-		Format: translate.Text,
-		Model:  "nmt",
-	})
-	return err
+	if tr.Formality != "" && tr.Formality != FormalityAuto {
+		tr.Cmd.Printf("Google Translate cannot be steered towards a %s register, ignoring formality", tr.Formality)
+	}
+	hits := tr.reusedResults()
+	sourceText, format := tr.protectTerms(sourceText)
+	pending := tr.cachedResults(sourceText, hits, tr.cacheKey(sourceText, format))
+	results := make([]translate.Translation, len(sourceText))
+	for i, text := range hits {
+		results[i] = translate.Translation{Text: text}
+	}
+	unique, repeats := tr.dedupe(sourceText, pending)
+	tr.Usage.Deduplicated = len(repeats)
+	if len(unique) > 0 {
+		tr.Usage.Requests++
+		for _, text := range pick(sourceText, unique) {
+			tr.Usage.Characters += utf8.RuneCountInString(text)
+		}
+		translated, err := tr.getClient().Translate(context.Background(), pick(sourceText, unique), tr.TargetLanguage, &translate.Options{
+			Source: tr.SourceLanguage,
+			Format: format,
+			Model:  tr.Usage.Model,
+		})
+		if err != nil {
+			return err
+		}
+		if len(translated) != len(unique) {
+			return fmt.Errorf("google returned %d translations for %d lines", len(translated), len(unique))
+		}
+		var texts []string
+		for n := range unique {
+			if format == translate.HTML {
+				translated[n].Text = unprotectTerms(translated[n].Text)
+			}
+			texts = append(texts, translated[n].Text)
+		}
+		texts = fanOut(pending, unique, repeats, texts)
+		for n, i := range pending {
+			results[i] = translate.Translation{Text: texts[n]}
+		}
+		tr.cacheResults(pending, texts, tr.cacheKey(sourceText, format))
+	}
+	tr.results = results
+	return nil
+}
+
+// cacheKey returns the cache key of every source text, as sent to Google
+func (tr *GoogleTranslateRequest) cacheKey(sourceText []string, format translate.Format) func(i int) CacheKey {
+	return func(i int) CacheKey {
+		return CacheKey{
+			Engine:         "google",
+			Model:          tr.Usage.Model,
+			Template:       string(format),
+			SourceLanguage: tr.SourceLanguage.String(),
+			TargetLanguage: tr.TargetLanguage.String(),
+			Source:         sourceText[i],
+		}
+	}
+}
+
+// DryRun returns the text that would be sent to Google Translate and the
+// characters it would be billed for, without contacting Google. Unchanged
+// cues, translation memory matches, cached cues and repeats cost nothing.
+func (tr *GoogleTranslateRequest) DryRun() (Usage, []string, error) {
+	usage := Usage{Engine: tr.Usage.Engine, Model: tr.Usage.Model}
+	sourceText, format := tr.protectTerms(tr.GetSourceText())
+	pending := tr.cachedResults(sourceText, tr.reusedResults(), tr.cacheKey(sourceText, format))
+	unique, repeats := tr.dedupe(sourceText, pending)
+	usage.Deduplicated = len(repeats)
+	if len(unique) == 0 {
+		return usage, nil, nil
+	}
+	usage.Requests = 1
+	for _, text := range pick(sourceText, unique) {
+		usage.Characters += utf8.RuneCountInString(text)
+	}
+	return usage, []string{strings.Join(pick(sourceText, unique), "\n")}, nil
+}
+
+// protectTerms wraps the do-not-translate glossary terms in spans marked
+// translate="no", which Google Translate leaves untouched in HTML mode. The
+// styling tags are kept as tags, which HTML mode moves along with the words
+// they wrap. Without such terms or tags the text is sent as plain text.
+func (tr *GoogleTranslateRequest) protectTerms(sourceText []string) ([]string, translate.Format) {
+	var dnt []string
+	for _, term := range tr.Glossary {
+		if term.DoNotTranslate {
+			dnt = append(dnt, regexp.QuoteMeta(html.EscapeString(term.Source)))
+		}
+	}
+	styled := slices.ContainsFunc(sourceText, styleTagPattern.MatchString)
+	if len(dnt) == 0 && !styled {
+		return sourceText, translate.Text
+	}
+	var pattern *regexp.Regexp
+	if len(dnt) > 0 {
+		// \b only knows ASCII letters, so a term is bounded by any non-letter
+		// like in GlossaryTerm.Matches; longer terms go first so a term is
+		// not cut short by another that starts it
+		slices.SortStableFunc(dnt, func(a, b string) int { return len(b) - len(a) })
+		pattern = regexp.MustCompile(`(?i)(^|\P{L})(` + strings.Join(dnt, "|") + `)`)
+	}
+	toReturn := make([]string, len(sourceText))
+	for i, text := range sourceText {
+		toReturn[i] = escapedStyleTag.ReplaceAllString(html.EscapeString(text), "<$1>")
+		if pattern != nil {
+			toReturn[i] = protectMatches(pattern, toReturn[i])
+		}
+	}
+	return toReturn, translate.HTML
+}
+
+// protectMatches wraps the terms matched by pattern that are not followed by
+// a letter in spans marked translate="no". The end of a term is checked
+// here, so the character after it can start the next match.
+func protectMatches(pattern *regexp.Regexp, text string) string {
+	var b strings.Builder
+	last := 0
+	for _, match := range pattern.FindAllStringSubmatchIndex(text, -1) {
+		if r, _ := utf8.DecodeRuneInString(text[match[1]:]); unicode.IsLetter(r) {
+			continue
+		}
+		b.WriteString(text[last:match[4]])
+		b.WriteString(`<span translate="no">` + text[match[4]:match[5]] + `</span>`)
+		last = match[5]
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+var escapedStyleTag = regexp.MustCompile(`&lt;(/?s\d+)&gt;`)
+
+var protectedSpan = regexp.MustCompile(`<span translate="no">(.*?)</span>`)
+
+func unprotectTerms(text string) string {
+	return html.UnescapeString(protectedSpan.ReplaceAllString(text, "$1"))
 }
 
 func (tr *GoogleTranslateRequest) GetTranslated() (*astisub.Subtitles, error) {
@@ -58,21 +193,13 @@ func (tr *GoogleTranslateRequest) GetTranslated() (*astisub.Subtitles, error) {
 			return nil, err
 		}
 	}
-	toReturn := astisub.NewSubtitles()
+	toReturn := tr.newTranslated()
 	for i, result := range tr.results {
-		toReturn.Items = append(toReturn.Items, &astisub.Item{
-			StartAt: tr.Subtitles.Items[i].StartAt,
-			EndAt:   tr.Subtitles.Items[i].EndAt,
-			Lines: []astisub.Line{
-				{
-					Items: []astisub.LineItem{
-						{
-							Text: result.Text,
-						},
-					},
-				},
-			},
-		})
+		text, err := tr.restoreText(i, result.Text)
+		if err != nil {
+			return nil, fmt.Errorf("cue %d: %w", i+1, err)
+		}
+		toReturn.Items = append(toReturn.Items, tr.translatedItem(tr.Subtitles.Items[i], text))
 	}
 	return toReturn, nil
 }
@@ -84,7 +211,7 @@ func (tr *GoogleTranslateRequest) getClient() *translate.Client {
 			tr.Cmd.Printf("GOOGLE_TRANSLATE_API_KEY environment variable not set")
 		}
 		var err error
-		tr.client, err = translate.NewClient(context.Background(), option.WithCredentialsFile(os.ExpandEnv("$HOME/.keys/subtitles-translator@dog-park-adjacent.iam.gserviceaccount.com.key")))
+		tr.client, err = translate.NewClient(context.Background(), option.WithCredentialsFile(googleCredentialsFile()))
 		if err != nil {
 			tr.Cmd.PrintErrf("Translate get client error: %s", err)
 		}
@@ -92,6 +219,11 @@ func (tr *GoogleTranslateRequest) getClient() *translate.Client {
 	return tr.client
 }
 
+// googleCredentialsFile returns the path of the service account key used by the Google Translate client
+func googleCredentialsFile() string {
+	return os.ExpandEnv("$HOME/.keys/subtitles-translator@dog-park-adjacent.iam.gserviceaccount.com.key")
+}
+
 func (tr *GoogleTranslateRequest) WriteTranslatedToNewFile() error {
 	fileName := strings.Replace(
 		tr.SubtitleFileName,
@@ -103,7 +235,12 @@ func (tr *GoogleTranslateRequest) WriteTranslatedToNewFile() error {
 	if err != nil {
 		return err
 	}
-	return translated.Write(fileName)
+	f, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return WriteTTML(f, translated, tr.TargetLanguage)
 }
 
 func (tr *GoogleTranslateRequest) GetTranslatedText() []string {
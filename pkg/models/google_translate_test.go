@@ -1,14 +1,21 @@
 package models
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/spf13/cobra"
+	"net/http"
+	"net/http/httptest"
+	"os"
 	"path"
 	"testing"
 
+	"cloud.google.com/go/translate"
 	"github.com/asticode/go-astisub"
 	"github.com/stovak/gpt-subtitles/pkg/util"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/option"
 )
 
 func TestGoogleTranslateRequest_Translate(t *testing.T) {
@@ -30,6 +37,9 @@ func TestGoogleTranslateRequest_Translate(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			if _, err := os.Stat(googleCredentialsFile()); err != nil {
+				t.Skip("Google Translate credentials not found")
+			}
 			tr, err := NewGoogleTranslationRequestFromFile(
 				path.Join(util.GetRoot(), "test-fixtures", tt.fileName),
 				tt.sourceLanguage, tt.destinationLanguage, &cobra.Command{},
@@ -46,3 +56,56 @@ func TestGoogleTranslateRequest_Translate(t *testing.T) {
 		})
 	}
 }
+
+func TestGoogleTranslateRequest_protectTerms(t *testing.T) {
+	tr := &GoogleTranslateRequest{
+		TranslationRequestBase: TranslationRequestBase{
+			Glossary: []GlossaryTerm{
+				{Source: "Detective", Target: "Detective"},
+				{Source: "Rolling Stone", Target: "Rolling Stone", DoNotTranslate: true},
+			},
+		},
+	}
+	protected, format := tr.protectTerms([]string{"Didn't you used work for Rolling Stone?", "Yes."})
+	assert.Equal(t, translate.HTML, format)
+	assert.Equal(t, `Didn&#39;t you used work for <span translate="no">Rolling Stone</span>?`, protected[0])
+	assert.Equal(t, "Yes.", protected[1])
+	assert.Equal(t, "¿No trabajabas para Rolling Stone?", unprotectTerms(`¿No trabajabas para <span translate="no">Rolling Stone</span>?`))
+
+	tr.Glossary = append(tr.Glossary, GlossaryTerm{Source: "Zoë", Target: "Zoë", DoNotTranslate: true}, GlossaryTerm{Source: "Émile", Target: "Émile", DoNotTranslate: true})
+	protected, _ = tr.protectTerms([]string{"Émile and Zoë Zoë, not Zoëlle or Émiles."})
+	assert.Equal(t, `<span translate="no">Émile</span> and <span translate="no">Zoë</span> <span translate="no">Zoë</span>, not Zoëlle or Émiles.`, protected[0], "terms are bounded by any non-letter")
+
+	tr.Glossary = tr.Glossary[:1]
+	_, format = tr.protectTerms([]string{"Yes."})
+	assert.Equal(t, translate.Text, format)
+}
+
+func TestGoogleTranslateRequest_DryRun(t *testing.T) {
+	tr, err := NewGoogleTranslationRequestFromFile(
+		path.Join(util.GetRoot(), "test-fixtures", "TestFixture1.ttml"),
+		"en", "es", &cobra.Command{})
+	assert.NoError(t, err, "NewGoogleTranslationRequestFromFile()")
+	usage, requests, err := tr.DryRun()
+	assert.NoError(t, err, "DryRun()")
+	assert.Len(t, requests, 1)
+	assert.Equal(t, "nmt", usage.Model)
+	assert.Equal(t, len([]rune(requests[0]))-len(tr.GetSourceText())+1, usage.Characters)
+}
+
+// newFakeGoogle returns a Google Translate client backed by a server that
+// translates every text with translateText
+func newFakeGoogle(t *testing.T, translateText func(string) string) *translate.Client {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var translations []map[string]string
+		for _, q := range r.URL.Query()["q"] {
+			translations = append(translations, map[string]string{"translatedText": translateText(q)})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"translations": translations}})
+	}))
+	t.Cleanup(server.Close)
+	client, err := translate.NewClient(context.Background(), option.WithEndpoint(server.URL+"/"), option.WithoutAuthentication())
+	assert.NoError(t, err, "translate.NewClient()")
+	return client
+}
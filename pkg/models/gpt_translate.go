@@ -3,11 +3,11 @@ package models
 import (
 	"context"
 	"fmt"
-	"html/template"
-	"log"
-	"os"
 	"path"
+	"strconv"
 	"strings"
+	"text/template"
+	"unicode/utf8"
 
 	"github.com/asticode/go-astisub"
 	"github.com/ayush6624/go-chatgpt"
@@ -17,14 +17,16 @@ import (
 
 type GPTTranslationRequest struct {
 	TranslationRequestBase
-	client          *chatgpt.Client
+	stream          *chatStream
 	results         []string
 	RequestTemplate *template.Template
 	SourceText      string
+	batchIndexes    []int
+	Model           chatgpt.ChatGPTModel
 }
 
 func NewGPTTranslationRequestFromFile(fileName string, sourceLanguage string, destinationLanguage string, cmd *cobra.Command) (TranslationRequest, error) {
-	subs, err := astisub.OpenFile(fileName)
+	subs, err := OpenSubtitles(fileName)
 	if err != nil {
 		return &GPTTranslationRequest{}, err
 	}
@@ -39,27 +41,46 @@ func NewGPTTranslationRequestFromFile(fileName string, sourceLanguage string, de
 			Cmd:              cmd,
 		},
 		SourceText:      "",
+		Model:           chatgpt.GPT4,
 		RequestTemplate: template.Must(template.ParseFiles(path.Join(util.GetRoot(), "templates/gpt-subtitle-request.tmpl"))),
 	}
 	toReturn.ParseSourceTarget(sourceLanguage, destinationLanguage)
+	toReturn.Usage.Engine = "gpt"
+	toReturn.Usage.Model = string(toReturn.Model)
+	toReturn.prompt = TemplateVersion(toReturn.RequestTemplate.Tree.Root.String())
 	return &toReturn, nil
 }
 
 func (tr *GPTTranslationRequest) Translate() error {
 	tr.Cmd.Printf("Translating: %s %s => %s", tr.SubtitleFileName, tr.SourceLanguage, tr.TargetLanguage)
 	sourceText := tr.GetSourceText()
-	// Iterate over the slice in batches of 100
-	for i := 0; i < len(sourceText); i += 100 {
-		sourceTextSlice := sourceText[i : i+100]
-		tr.Logger.Debugf("Translating %d lines", len(sourceTextSlice))
+	hits := tr.reusedResults()
+	err := tr.resumedResults(tr.checkpointKey(string(tr.Model), tr.prompt, sourceText), hits)
+	if err != nil {
+		return err
+	}
+	pending := tr.cachedResults(sourceText, hits, tr.cacheKey(sourceText))
+	unique, repeats := tr.dedupe(sourceText, pending)
+	tr.Usage.Deduplicated = len(repeats)
+	var results []string
+	for _, batch := range tr.batches(unique) {
+		sourceTextSlice := pick(sourceText, batch)
+		err := tr.checkSpending()
+		if err != nil {
+			return fmt.Errorf("stopping with %d of %d cues translated, resume from the checkpoint: %w", len(hits)+len(results), len(sourceText), err)
+		}
+		tr.Cmd.Printf("Translating %d lines", len(sourceTextSlice))
+		stream, err := tr.getStream()
+		if err != nil {
+			return err
+		}
 		// Call the function with the current batch of strings
-		prompt, err := tr.toPrompt(sourceTextSlice)
+		prompt, err := tr.toPrompt(batch, sourceTextSlice)
 		if err != nil {
 			return err
 		}
-		tr.Cmd.Printf("Prompt: %s", prompt)
 		req := chatgpt.ChatCompletionRequest{
-			Model: chatgpt.GPT4,
+			Model: tr.Model,
 			Messages: []chatgpt.ChatMessage{
 				{
 					Role:    chatgpt.ChatGPTModelRoleSystem,
@@ -67,26 +88,114 @@ func (tr *GPTTranslationRequest) Translate() error {
 				},
 			},
 		}
-		tr.Cmd.Printf("Sending a batch of %d lines to OpenAI", len(sourceText[i:i+100]))
-		resp, err := tr.getClient().Send(context.Background(), &req)
+		tr.Cmd.Printf("Sending a batch of %d lines to OpenAI", len(sourceTextSlice))
+		monitor := newStreamMonitor(tr.TargetLanguage, sourceTextSlice)
+		reported := 0
+		content, usage, err := stream.Complete(context.Background(), &req, func(content string) error {
+			// every cue but the last one is complete
+			cues := strings.Split(content, "|")
+			cues = cues[:len(cues)-1]
+			for ; reported < len(cues) && reported < len(batch); reported++ {
+				tr.Cmd.Printf("%s => %s: cue %d/%d: %s\n", tr.SourceLanguage, tr.TargetLanguage, batch[reported]+1, len(sourceText), strings.TrimSpace(cues[reported]))
+			}
+			return monitor.Check(cues, content)
+		})
+		tr.Usage.Requests++
+		tr.Usage.PromptTokens += usage.Prompt_Tokens
+		tr.Usage.CompletionTokens += usage.Completion_Tokens
+		tr.Usage.Characters += utf8.RuneCountInString(tr.SourceText)
 		if err != nil {
-			return err
-		}
-		if len(resp.Choices) == 0 {
-			return fmt.Errorf("no choices returned")
+			return fmt.Errorf("batch starting at line %d: %w", batch[0]+1, err)
 		}
 		// Split the results and then add them all to the slice of strings for results
-		tr.results = append(tr.results, strings.Split(resp.Choices[0].Message.Content, "|")...)
-		tr.Cmd.Printf("%d Results total", len(tr.results))
+		batchResults := strings.Split(content, "|")
+		tr.cacheResults(batch, batchResults, tr.cacheKey(sourceText))
+		tr.checkpointResults(batch, batchResults)
+		results = append(results, batchResults...)
+		tr.Cmd.Printf("%d Results total", len(hits)+len(results))
+	}
+	if len(repeats) > 0 {
+		results = fanOut(pending, unique, repeats, results)
+		tr.cacheResults(pending, results, tr.cacheKey(sourceText))
+	}
+	tr.results = mergeResults(len(sourceText), hits, pending, results)
+	tr.finishCheckpoint()
+	return nil
+}
 
+// cacheKey returns the cache key of every source text. Besides the prompt
+// context, a cue is keyed by its length limit, its translation memory
+// references and its series terms, which the model sees as well. Its
+// neighbours are left out so editing a cue only invalidates that cue.
+func (tr *GPTTranslationRequest) cacheKey(sourceText []string) func(i int) CacheKey {
+	context := tr.cacheContext()
+	references := func(i int) string {
+		var toReturn []string
+		for _, match := range tr.memoryReferences(i) {
+			toReturn = append(toReturn, match.Source+"=>"+match.Target)
+		}
+		return TemplateVersion(strings.Join(toReturn, "\n"))
+	}
+	limit := func(i int) string {
+		if tr.Length == nil || i >= len(tr.Subtitles.Items) {
+			return ""
+		}
+		return strconv.Itoa(tr.Length.Limit(tr.Subtitles.Items[i]))
 	}
+	return func(i int) CacheKey {
+		return CacheKey{
+			Engine:         tr.Usage.Engine,
+			Model:          string(tr.Model),
+			Template:       tr.prompt,
+			SourceLanguage: tr.SourceLanguage.String(),
+			TargetLanguage: tr.TargetLanguage.String(),
+			Context:        strings.Join([]string{context, limit(i), references(i), tr.seriesContext(sourceText[i])}, "|"),
+			Source:         sourceText[i],
+		}
+	}
+}
 
-	return nil
+// gptBatchSize is the number of lines sent to OpenAI in a single request
+const gptBatchSize = 100
+
+// batches splits the indexes of the source text to translate into the batches sent to OpenAI
+func (tr *GPTTranslationRequest) batches(pending []int) [][]int {
+	var toReturn [][]int
+	for i := 0; i < len(pending); i += gptBatchSize {
+		toReturn = append(toReturn, pending[i:min(i+gptBatchSize, len(pending))])
+	}
+	return toReturn
+}
+
+// DryRun renders the prompt of every batch and estimates the tokens they
+// would use, without contacting OpenAI. The translation is assumed to be
+// about as long as the source text. Unchanged cues, translation memory
+// matches, cached cues and repeats cost nothing.
+func (tr *GPTTranslationRequest) DryRun() (Usage, []string, error) {
+	usage := Usage{Engine: tr.Usage.Engine, Model: tr.Usage.Model}
+	var prompts []string
+	sourceText := tr.GetSourceText()
+	pending := tr.cachedResults(sourceText, tr.reusedResults(), tr.cacheKey(sourceText))
+	unique, repeats := tr.dedupe(sourceText, pending)
+	usage.Deduplicated = len(repeats)
+	for _, batch := range tr.batches(unique) {
+		prompt, err := tr.toPrompt(batch, pick(sourceText, batch))
+		if err != nil {
+			return usage, prompts, err
+		}
+		prompts = append(prompts, prompt)
+		usage.Requests++
+		usage.PromptTokens += EstimateTokens(prompt)
+		usage.CompletionTokens += EstimateTokens(tr.SourceText)
+		usage.Characters += utf8.RuneCountInString(tr.SourceText)
+	}
+	return usage, prompts, nil
 }
 
-func (tr *GPTTranslationRequest) toPrompt(batch []string) (string, error) {
+func (tr *GPTTranslationRequest) toPrompt(indexes []int, batch []string) (string, error) {
 	var err error
 	var buf = new(strings.Builder)
+	tr.batchIndexes = indexes
 	// String the source text together in a single string separated by |
 	tr.SourceText = strings.Join(batch, "|")
 	// Execute the template and capture the output
@@ -95,19 +204,60 @@ func (tr *GPTTranslationRequest) toPrompt(batch []string) (string, error) {
 	return buf.String(), err
 }
 
-func (tr *GPTTranslationRequest) getClient() *chatgpt.Client {
-	if tr.client == nil {
-		key := os.Getenv("OPENAI_API_KEY")
-		if key == "" {
-			log.Fatal("OPENAI_API_KEY environment variable not set")
+// HasPlaceholders reports whether the batch being prompted contains protected spans
+func (tr *GPTTranslationRequest) HasPlaceholders() bool {
+	return placeholderPattern.MatchString(tr.SourceText)
+}
+
+// HasStyleTags reports whether the batch being prompted contains styled spans
+func (tr *GPTTranslationRequest) HasStyleTags() bool {
+	return styleTagPattern.MatchString(tr.SourceText)
+}
+
+// CueLimits returns the maximum number of characters of every cue of the
+// batch being prompted, separated by "|", or "" without length limits
+func (tr *GPTTranslationRequest) CueLimits() string {
+	if tr.Length == nil {
+		return ""
+	}
+	return tr.Length.limits(tr.Subtitles, tr.batchIndexes)
+}
+
+// MemoryReferences returns the fuzzy translation memory matches of the cues
+// of the batch being prompted
+func (tr *GPTTranslationRequest) MemoryReferences() []FuzzyMatch {
+	var toReturn []FuzzyMatch
+	seen := map[string]bool{}
+	for _, i := range tr.batchIndexes {
+		for _, match := range tr.memoryReferences(i) {
+			if !seen[match.Source] {
+				seen[match.Source] = true
+				toReturn = append(toReturn, match)
+			}
 		}
+	}
+	return toReturn
+}
+
+// SeriesTerms returns the recurring lines and names of the series used in the batch being prompted
+func (tr *GPTTranslationRequest) SeriesTerms() []GlossaryTerm {
+	return tr.SeriesTermsFor(strings.Split(tr.SourceText, "|")...)
+}
+
+// GlossaryTerms returns the glossary terms used in the batch being prompted
+func (tr *GPTTranslationRequest) GlossaryTerms() []GlossaryTerm {
+	return tr.GlossaryTermsFor(strings.Split(tr.SourceText, "|")...)
+}
+
+func (tr *GPTTranslationRequest) getStream() (*chatStream, error) {
+	if tr.stream == nil {
 		var err error
-		tr.client, err = chatgpt.NewClient(key)
+		tr.stream, err = newChatStream()
 		if err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
 	}
-	return tr.client
+	return tr.stream, nil
 }
 
 // GetTranslated returns a new Subtitles object with the translated text
@@ -116,34 +266,18 @@ func (tr *GPTTranslationRequest) GetTranslated() (*astisub.Subtitles, error) {
 	if tr.results == nil {
 		return nil, fmt.Errorf("no results to translate")
 	}
-	toReturn := astisub.NewSubtitles()
-	r, _ := tr.TargetLanguage.Region()
-	region := &astisub.Region{
-		ID: r.String(),
-	}
-
-	toReturn.Regions = map[string]*astisub.Region{
-		region.ID: region,
-	}
+	toReturn := tr.newTranslated()
 	if len(tr.results) != len(tr.Subtitles.Items) {
 		_ = tr.WriteErrorDiff(tr.results)
 		return nil, fmt.Errorf("number of lines in result (%d) does not match number of lines in source (%d)", len(tr.results), len(tr.Subtitles.Items))
 	}
 	for num, item := range tr.Subtitles.Items {
-		toReturn.Items = append(toReturn.Items, &astisub.Item{
-			Region:  region,
-			StartAt: item.StartAt,
-			EndAt:   item.EndAt,
-			Lines: []astisub.Line{
-				{
-					Items: []astisub.LineItem{
-						{
-							Text: tr.results[num],
-						},
-					},
-				},
-			},
-		})
+		text, err := tr.restoreText(num, tr.results[num])
+		if err != nil {
+			_ = tr.WriteErrorDiff(tr.results)
+			return nil, fmt.Errorf("cue %d: %w", num+1, err)
+		}
+		toReturn.Items = append(toReturn.Items, tr.translatedItem(item, text))
 	}
 	return toReturn, nil
 }
@@ -2,10 +2,12 @@ package models
 
 import (
 	"fmt"
+	"os"
 	"path"
 	"testing"
 
 	"github.com/asticode/go-astisub"
+	"github.com/spf13/cobra"
 	"github.com/stovak/gpt-subtitles/pkg/util"
 	"github.com/stretchr/testify/assert"
 )
@@ -29,9 +31,12 @@ func TestGPTTranslateRequest_Translate(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			if os.Getenv("OPENAI_API_KEY") == "" {
+				t.Skip("OPENAI_API_KEY environment variable not set")
+			}
 			tr, err := NewGPTTranslationRequestFromFile(
 				path.Join(util.GetRoot(), "test-fixtures", tt.fileName),
-				tt.sourceLanguage, tt.destinationLanguage, observedLogger.Sugar())
+				tt.sourceLanguage, tt.destinationLanguage, &cobra.Command{})
 			assert.NoError(t, err, fmt.Sprintf("NewGoogleTranslationRequestFromFile(%s, %s, %s)", tt.fileName, tt.sourceLanguage, tt.destinationLanguage))
 			t.Logf("tr: %#v", tr)
 			st := tr.GetSourceText()
@@ -46,3 +51,48 @@ func TestGPTTranslateRequest_Translate(t *testing.T) {
 		})
 	}
 }
+
+func TestGPTTranslateRequest_toPrompt(t *testing.T) {
+	tr, err := NewGPTTranslationRequestFromFile(
+		path.Join(util.GetRoot(), "test-fixtures", "TestFixture1.ttml"),
+		"en", "es", &cobra.Command{})
+	assert.NoError(t, err, "NewGPTTranslationRequestFromFile()")
+	gpt := tr.(*GPTTranslationRequest)
+	gpt.AddExamples([]Example{{Source: "Yes.", Target: "Sí."}}, 0)
+	gpt.Bible = &ShowBible{Characters: []Character{{Name: "Detective", Gender: "female"}}}
+	prompt, err := gpt.toPrompt([]int{0}, []string{"I'd like to report an emergency."})
+	assert.NoError(t, err, "toPrompt()")
+	assert.Contains(t, prompt, "Yes. => Sí.", "prompt does not contain the example")
+	assert.Contains(t, prompt, "- Detective (female)", "prompt does not contain the show bible")
+	assert.Contains(t, prompt, "I'd like to report an emergency.", "prompt does not contain the source text")
+}
+
+func TestGPTTranslateRequest_Translate_stream(t *testing.T) {
+	newFakeOpenAI(t, fakeTranslation)
+	tr, err := NewGPTTranslationRequestFromFile(
+		path.Join(util.GetRoot(), "test-fixtures", "TestFixture1.ttml"),
+		"en", "es", &cobra.Command{})
+	assert.NoError(t, err, "NewGPTTranslationRequestFromFile()")
+	err = tr.Translate()
+	assert.NoError(t, err, "Translate()")
+	translated := tr.GetTranslatedText()
+	assert.Len(t, translated, len(tr.GetSourceText()), "one result per source line")
+	assert.Equal(t, "ES Yes.", translated[1])
+	usage := tr.GetUsage()
+	assert.Equal(t, 2, usage.Requests, "174 lines are sent in two batches")
+	assert.Equal(t, 200, usage.PromptTokens)
+	assert.Equal(t, "TestFixture1.ttml", usage.File)
+}
+
+func TestGPTTranslateRequest_DryRun(t *testing.T) {
+	tr, err := NewGPTTranslationRequestFromFile(
+		path.Join(util.GetRoot(), "test-fixtures", "TestFixture1.ttml"),
+		"en", "es", &cobra.Command{})
+	assert.NoError(t, err, "NewGPTTranslationRequestFromFile()")
+	usage, prompts, err := tr.DryRun()
+	assert.NoError(t, err, "DryRun()")
+	assert.Len(t, prompts, 2, "174 lines are sent in two batches")
+	assert.Equal(t, 2, usage.Requests)
+	assert.Greater(t, usage.PromptTokens, usage.CompletionTokens, "prompts include the instructions")
+	assert.Contains(t, prompts[1], "And this all started because they needed a lawyer.")
+}
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"fmt"
+
+	"github.com/asticode/go-astisub"
+	"github.com/spf13/cobra"
+)
+
+// HybridTranslationRequest drafts the translation with Google Translate and
+// has an LLM post-edit the draft for fluency, glossary compliance and
+// subtitle length. It combines Google's cost and consistent line counts with
+// the quality of the LLM. Its dry run only covers the Google draft, as the
+// post-editing prompts depend on the draft.
+type HybridTranslationRequest struct {
+	GoogleTranslateRequest
+	PostEditor *Reviewer
+	translated *astisub.Subtitles
+	edits      []Edit
+}
+
+func NewHybridTranslationRequestFromFile(fileName string, sourceLanguage string, destinationLanguage string, cmd *cobra.Command) (TranslationRequest, error) {
+	draft, err := NewGoogleTranslationRequestFromFile(fileName, sourceLanguage, destinationLanguage, cmd)
+	if err != nil {
+		return &HybridTranslationRequest{}, err
+	}
+	toReturn := HybridTranslationRequest{
+		GoogleTranslateRequest: *draft.(*GoogleTranslateRequest),
+		PostEditor:             NewReviewer("gpt-post-edit-request.tmpl"),
+	}
+	toReturn.Usage.Engine = "hybrid"
+	toReturn.PostEditor.Usage.Engine = "post-edit"
+	toReturn.prompt = TemplateVersion(toReturn.PostEditor.RequestTemplate.Tree.Root.String())
+	return &toReturn, nil
+}
+
+// Translate drafts the translation with Google Translate and post-edits it.
+// When post-editing fails, the draft is kept with the edits made so far.
+func (tr *HybridTranslationRequest) Translate() error {
+	err := tr.GoogleTranslateRequest.Translate()
+	if err != nil {
+		return err
+	}
+	tr.translated, err = tr.GoogleTranslateRequest.GetTranslated()
+	if err != nil {
+		return err
+	}
+	tr.edits, err = tr.PostEditor.Review(&tr.TranslationRequestBase, tr.translated)
+	if err != nil {
+		tr.Cmd.PrintErrf("Error post-editing the Google Translate draft, keeping the draft with %d edits: %s\n", len(tr.edits), err)
+	}
+	tr.Cmd.Printf("Post-editing changed %d of %d cues", len(tr.edits), len(tr.translated.Items))
+	return nil
+}
+
+// GetPostEdits returns the corrections post-editing made to the draft
+func (tr *HybridTranslationRequest) GetPostEdits() []Edit {
+	return tr.edits
+}
+
+// GetTranslated returns the post-edited translation
+func (tr *HybridTranslationRequest) GetTranslated() (*astisub.Subtitles, error) {
+	if tr.translated == nil {
+		return nil, fmt.Errorf("no results to translate")
+	}
+	return tr.translated, nil
+}
+
+// GetTranslatedText returns the text of the post-edited cues, or of the draft
+// if post-editing has not finished
+func (tr *HybridTranslationRequest) GetTranslatedText() []string {
+	if tr.translated == nil {
+		return tr.GoogleTranslateRequest.GetTranslatedText()
+	}
+	var toReturn []string
+	for _, item := range tr.translated.Items {
+		toReturn = append(toReturn, cueText(item))
+	}
+	return toReturn
+}
+
+// EstimatePasses adds the estimate of post-editing every cue of the draft
+func (tr *HybridTranslationRequest) EstimatePasses() ([]Usage, error) {
+	toReturn, err := tr.GoogleTranslateRequest.EstimatePasses()
+	if err != nil {
+		return toReturn, err
+	}
+	usage, err := tr.PostEditor.estimate(&tr.TranslationRequestBase, allCues(&tr.TranslationRequestBase, tr.Subtitles))
+	if usage.Requests > 0 {
+		toReturn = append([]Usage{tr.labelUsage(usage)}, toReturn...)
+	}
+	return toReturn, err
+}
+
+// GetPassUsage adds the usage of the post-editing LLM to the passes of the request
+func (tr *HybridTranslationRequest) GetPassUsage() []Usage {
+	toReturn := tr.GoogleTranslateRequest.GetPassUsage()
+	if tr.PostEditor.Usage.Requests > 0 {
+		toReturn = append([]Usage{tr.labelUsage(tr.PostEditor.Usage)}, toReturn...)
+	}
+	return toReturn
+}
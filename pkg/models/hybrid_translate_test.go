@@ -0,0 +1,66 @@
+package models
+
+import (
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stovak/gpt-subtitles/pkg/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHybridTranslationRequest_Translate(t *testing.T) {
+	newFakeOpenAI(t, func(prompt string) string {
+		if strings.Contains(prompt, "\n2. Yes.\n=> MT Yes.\n") {
+			return `[{"cue": 2, "translation": "Sí.", "reason": "untranslated"}]`
+		}
+		return "[]"
+	})
+	fileName := path.Join(t.TempDir(), "TestFixture1.ttml")
+	tr, err := NewHybridTranslationRequestFromFile(
+		path.Join(util.GetRoot(), "test-fixtures", "TestFixture1.ttml"),
+		"en", "es", &cobra.Command{})
+	assert.NoError(t, err, "NewHybridTranslationRequestFromFile()")
+	hybrid := tr.(*HybridTranslationRequest)
+	hybrid.SubtitleFileName = fileName
+	hybrid.client = newFakeGoogle(t, func(text string) string { return "MT " + text })
+
+	err = tr.Translate()
+	assert.NoError(t, err, "Translate()")
+	translated, err := tr.GetTranslated()
+	assert.NoError(t, err, "GetTranslated()")
+	assert.Equal(t, "Sí.", translated.Items[1].String())
+	assert.Equal(t, "MT Legally.", translated.Items[11].String())
+	assert.Equal(t, "Sí.", tr.GetTranslatedText()[1])
+	assert.Len(t, tr.GetPostEdits(), 1)
+	assert.NoFileExists(t, path.Join(path.Dir(fileName), "TestFixture1_es-post-edit.txt"), "the edit log is written with the translation")
+
+	assert.Equal(t, "hybrid", tr.GetUsage().Engine)
+	passes := tr.GetPassUsage()
+	assert.Len(t, passes, 1)
+	assert.Equal(t, "post-edit", passes[0].Engine)
+	assert.Equal(t, 4, passes[0].Requests)
+}
+
+func TestHybridTranslationRequest_Translate_postEditFailure(t *testing.T) {
+	newFakeOpenAI(t, func(prompt string) string {
+		if strings.Contains(prompt, "\n2. Yes.\n=> MT Yes.\n") {
+			return `[{"cue": 2, "translation": "Sí."}]`
+		}
+		return "not JSON"
+	})
+	tr, err := NewHybridTranslationRequestFromFile(
+		path.Join(util.GetRoot(), "test-fixtures", "TestFixture1.ttml"),
+		"en", "es", &cobra.Command{})
+	assert.NoError(t, err, "NewHybridTranslationRequestFromFile()")
+	hybrid := tr.(*HybridTranslationRequest)
+	hybrid.client = newFakeGoogle(t, func(text string) string { return "MT " + text })
+
+	assert.NoError(t, tr.Translate(), "a failed post-edit keeps the draft")
+	translated, err := tr.GetTranslated()
+	assert.NoError(t, err, "GetTranslated()")
+	assert.Equal(t, "Sí.", translated.Items[1].String(), "the edits made so far are kept")
+	assert.Equal(t, "MT Legally.", translated.Items[11].String())
+	assert.Len(t, tr.GetPostEdits(), 1)
+}
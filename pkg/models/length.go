@@ -0,0 +1,124 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/asticode/go-astisub"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+// LengthLimits derive the maximum number of characters of a cue from how
+// long it is on screen and how much fits on screen
+type LengthLimits struct {
+	// CharsPerSecond is the target reading speed; zero disables the limits
+	CharsPerSecond float64 `mapstructure:"cps"`
+	LineLength     int     `mapstructure:"line_length"`
+	Lines          int     `mapstructure:"lines"`
+	// Attempts is how many times overflowing cues are sent back to be shortened
+	Attempts int `mapstructure:"attempts"`
+}
+
+// Enabled reports whether a reading speed is set
+func (l LengthLimits) Enabled() bool {
+	return l.CharsPerSecond > 0
+}
+
+// Limit returns the maximum number of characters of the cue: what can be
+// read at the target speed while it is on screen, capped by what fits in
+// its lines
+func (l LengthLimits) Limit(item *astisub.Item) int {
+	toReturn := int((item.EndAt - item.StartAt).Seconds() * l.CharsPerSecond)
+	if l.LineLength > 0 && l.Lines > 0 {
+		toReturn = min(toReturn, l.LineLength*l.Lines)
+	}
+	return max(toReturn, 1)
+}
+
+// CueLength is the number of characters of the cue, not counting line breaks
+func CueLength(item *astisub.Item) int {
+	toReturn := 0
+	for _, line := range item.Lines {
+		toReturn += utf8.RuneCountInString(line.String())
+	}
+	return toReturn
+}
+
+// Overflowing returns the indexes of the translated cues longer than their limit
+func (l LengthLimits) Overflowing(source *astisub.Subtitles, translated *astisub.Subtitles) []int {
+	var toReturn []int
+	for i := range min(len(source.Items), len(translated.Items)) {
+		if CueLength(translated.Items[i]) > l.Limit(source.Items[i]) {
+			toReturn = append(toReturn, i)
+		}
+	}
+	return toReturn
+}
+
+// limits returns the character limit of the cues at the given indexes, separated by "|"
+func (l LengthLimits) limits(source *astisub.Subtitles, indexes []int) string {
+	var toReturn []string
+	for _, i := range indexes {
+		if i < len(source.Items) {
+			toReturn = append(toReturn, strconv.Itoa(l.Limit(source.Items[i])))
+		}
+	}
+	return strings.Join(toReturn, "|")
+}
+
+// Shortener is an LLM pass that sends the cues longer than their limit back
+// to be shortened, until they fit or the attempts run out
+type Shortener struct {
+	cuePass
+}
+
+// NewShortener creates a shortener prompting with the named template from the templates directory
+func NewShortener(templateName string) *Shortener {
+	return &Shortener{
+		cuePass: newCuePass(templateName, "shorten"),
+	}
+}
+
+// Shorten applies the shortened translations to translated and returns them
+// as edits, along with the indexes of the cues that are still too long
+func (s *Shortener) Shorten(tr *TranslationRequestBase, translated *astisub.Subtitles) ([]Edit, []int, error) {
+	var edits []Edit
+	overflowing := tr.Length.Overflowing(tr.Subtitles, translated)
+	for attempt := 0; attempt < max(tr.Length.Attempts, 1) && len(overflowing) > 0; attempt++ {
+		err := s.run(tr, translated, overflowing, "Shortening", func(content string, batch []int) error {
+			batchEdits, err := parseEdits(content, batch)
+			if err != nil {
+				return err
+			}
+			for _, edit := range batchEdits {
+				edit.Source, edit.Before = taggedCue(tr.Subtitles.Items[edit.Index], translated.Items[edit.Index])
+				if utf8.RuneCountInString(styleTagPattern.ReplaceAllString(edit.After, "")) >= CueLength(translated.Items[edit.Index]) {
+					continue
+				}
+				if edit.Reason == "" {
+					edit.Reason = fmt.Sprintf("longer than %d characters", tr.Length.Limit(tr.Subtitles.Items[edit.Index]))
+				}
+				translated.Items[edit.Index].Lines = tr.cueLines(tr.Subtitles.Items[edit.Index], edit.After)
+				edits = append(edits, edit)
+			}
+			return nil
+		})
+		if err != nil {
+			return edits, overflowing, err
+		}
+		overflowing = tr.Length.Overflowing(tr.Subtitles, translated)
+	}
+	return edits, overflowing, nil
+}
+
+// WriteLengthReport writes a table of the cues that are still longer than their limit next to the source file
+func (tr *TranslationRequestBase) WriteLengthReport(translated *astisub.Subtitles, overflowing []int) error {
+	t := table.NewWriter()
+	t.AppendHeader(table.Row{"#", "Time", "Translation", "Length", "Limit"})
+	for _, i := range overflowing {
+		t.AppendRow(table.Row{i + 1, FormatTimecode(tr.Subtitles.Items[i].StartAt), cueText(translated.Items[i]), CueLength(translated.Items[i]), tr.Length.Limit(tr.Subtitles.Items[i])})
+	}
+	return tr.WriteReport(fmt.Sprintf("%s-length", tr.TargetLanguage), t.Render())
+}
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/asticode/go-astisub"
+	"github.com/spf13/cobra"
+	"github.com/stovak/gpt-subtitles/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/language"
+)
+
+func TestLengthLimits_Limit(t *testing.T) {
+	limits := LengthLimits{CharsPerSecond: 17, LineLength: 42, Lines: 2}
+	tests := []struct {
+		name     string
+		duration time.Duration
+		want     int
+	}{
+		{name: "Limit-short", duration: 1500 * time.Millisecond, want: 25},
+		{name: "Limit-two-lines", duration: 10 * time.Second, want: 84},
+		{name: "Limit-flash", duration: 10 * time.Millisecond, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item := &astisub.Item{StartAt: time.Minute, EndAt: time.Minute + tt.duration}
+			assert.Equal(t, tt.want, limits.Limit(item))
+		})
+	}
+}
+
+func TestCueLength(t *testing.T) {
+	item := &astisub.Item{Lines: []astisub.Line{
+		{Items: []astisub.LineItem{{Text: "¿Dónde está "}, {Text: "mi abogado?"}}},
+		{Items: []astisub.LineItem{{Text: "Ahora."}}},
+	}}
+	assert.Equal(t, 29, CueLength(item), "line items count, line breaks do not")
+}
+
+func TestShortener_Shorten(t *testing.T) {
+	var prompts []string
+	newFakeOpenAI(t, func(prompt string) string {
+		prompts = append(prompts, prompt)
+		if len(prompts) == 1 {
+			return `[{"cue": 2, "translation": "Sí."}, {"cue": 10, "translation": "¿Y mi abogado, dónde está, por favor, dónde está?"}]`
+		}
+		return `[{"cue": 10, "translation": "¿Y mi abogado?"}]`
+	})
+	tr := &TranslationRequestBase{
+		SubtitleFileName: path.Join(util.GetRoot(), "test-fixtures", "TestFixture1.ttml"),
+		Cmd:              &cobra.Command{},
+		SourceLanguage:   language.English,
+		TargetLanguage:   language.Spanish,
+		Length:           &LengthLimits{CharsPerSecond: 17, LineLength: 42, Lines: 2, Attempts: 2},
+	}
+	assert.NoError(t, tr.Parse(), "Parse()")
+	translated := translatedCopy(tr, func(s string) string { return "Sí." })
+	setItemText(translated.Items[1], strings.Repeat("Sí, sí, sí. ", 10))
+	setItemText(translated.Items[9], strings.Repeat("¿Dónde está mi abogado? ", 10))
+	assert.Equal(t, []int{1, 9}, tr.Length.Overflowing(tr.Subtitles, translated))
+
+	edits, overflowing, err := NewShortener("gpt-shorten-request.tmpl").Shorten(tr, translated)
+	assert.NoError(t, err, "Shorten()")
+	assert.Len(t, prompts, 2, "the cue still too long is sent back once more")
+	assert.Contains(t, prompts[0], "(at most ", "prompt does not contain the limits")
+	assert.NotContains(t, prompts[1], "\n2. Yes.\n", "only the overflowing cue is sent back")
+	assert.Empty(t, overflowing)
+	assert.Len(t, edits, 3)
+	assert.Equal(t, "Sí.", translated.Items[1].String())
+	assert.Equal(t, "¿Y mi abogado?", translated.Items[9].String())
+}
+
+func TestGPTTranslateRequest_toPrompt_limits(t *testing.T) {
+	tr, err := NewGPTTranslationRequestFromFile(
+		path.Join(util.GetRoot(), "test-fixtures", "TestFixture1.ttml"),
+		"en", "es", &cobra.Command{})
+	assert.NoError(t, err, "NewGPTTranslationRequestFromFile()")
+	gpt := tr.(*GPTTranslationRequest)
+	assert.NoError(t, gpt.Parse(), "Parse()")
+	batch := gpt.GetSourceText()[1:3]
+	prompt, err := gpt.toPrompt([]int{1, 2}, batch)
+	assert.NoError(t, err, "toPrompt()")
+	assert.NotContains(t, prompt, "maximum number of characters", "no limits without a reading speed")
+
+	gpt.Length = &LengthLimits{CharsPerSecond: 17, LineLength: 42, Lines: 2}
+	prompt, err = gpt.toPrompt([]int{1, 2}, batch)
+	assert.NoError(t, err, "toPrompt()")
+	limits := gpt.Length.limits(gpt.Subtitles, []int{1, 2})
+	assert.Len(t, strings.Split(limits, "|"), 2)
+	assert.Contains(t, prompt, "maximum number of characters")
+	assert.Contains(t, prompt, "\n"+limits+"\n")
+}
@@ -0,0 +1,152 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/asticode/go-astisub"
+)
+
+// ManifestCue records the translation produced for the text of a source cue
+type ManifestCue struct {
+	Hash        string `json:"hash"`
+	Source      string `json:"source"`
+	Translation string `json:"translation"`
+}
+
+// Manifest is written next to every output and records what each source cue
+// was translated to, so a later run on an edited source only sends the cues
+// whose text changed to the engine
+type Manifest struct {
+	SourceFile     string `json:"source_file"`
+	SourceLanguage string `json:"source_language"`
+	TargetLanguage string `json:"target_language"`
+	Engine         string `json:"engine"`
+	// Context identifies the engine, model, prompt, register, glossary, show bible, examples and length limits of the translation
+	Context string        `json:"context"`
+	Created time.Time     `json:"created"`
+	Cues    []ManifestCue `json:"cues"`
+}
+
+// cueHash identifies the text of a source cue
+func cueHash(text string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(text)))
+	return hex.EncodeToString(sum[:])
+}
+
+// LoadManifest reads a manifest; a missing file returns nil
+func LoadManifest(fileName string) (*Manifest, error) {
+	contents, err := os.ReadFile(fileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var toReturn Manifest
+	err = json.Unmarshal(contents, &toReturn)
+	if err != nil {
+		return nil, fmt.Errorf("parsing manifest %s: %w", fileName, err)
+	}
+	return &toReturn, nil
+}
+
+// ManifestFileName returns the name of the manifest of the translation, e.g. movie_es-manifest.json
+func (tr *TranslationRequestBase) ManifestFileName() string {
+	return strings.TrimSuffix(tr.SubtitleFileName, filepath.Ext(tr.SubtitleFileName)) + fmt.Sprintf("_%s-manifest.json", tr.TargetLanguage)
+}
+
+// WriteManifest records the translation of every source cue next to the source file
+func (tr *TranslationRequestBase) WriteManifest(translated *astisub.Subtitles) error {
+	manifest := Manifest{
+		SourceFile:     filepath.Base(tr.SubtitleFileName),
+		SourceLanguage: tr.SourceLanguage.String(),
+		TargetLanguage: tr.TargetLanguage.String(),
+		Engine:         tr.Usage.Engine,
+		Context:        tr.manifestContext(),
+		Created:        time.Now(),
+	}
+	for i, item := range tr.Subtitles.Items {
+		if i >= len(translated.Items) {
+			break
+		}
+		// the translation is tagged with the styles of the source, so they are restored when it is reused
+		source, styles := taggedText(item, nil)
+		translation, _ := taggedText(translated.Items[i], styles)
+		manifest.Cues = append(manifest.Cues, ManifestCue{
+			Hash:        cueHash(source),
+			Source:      source,
+			Translation: translation,
+		})
+	}
+	contents, err := json.MarshalIndent(manifest, "", "    ")
+	if err != nil {
+		return err
+	}
+	tr.Cmd.Printf("Writing manifest to %s", tr.ManifestFileName())
+	return os.WriteFile(tr.ManifestFileName(), contents, 0644)
+}
+
+// manifestContext identifies everything besides the source text that went
+// into the translation, as the cache and checkpoint keys do: the engine, its
+// model and prompt template, the prompt context and the length limits. A
+// manifest of another context is not reused.
+func (tr *TranslationRequestBase) manifestContext() string {
+	var limits []byte
+	if tr.Length != nil {
+		limits, _ = json.Marshal(tr.Length)
+	}
+	return TemplateVersion(strings.Join([]string{tr.Usage.Engine, tr.Usage.Model, tr.prompt, tr.cacheContext(), string(limits)}, "|"))
+}
+
+// manifestResults returns the translations of the previous run for the
+// cues whose text did not change, by index of the source text. Timing
+// changes do not matter, the timing always comes from the source. Nothing
+// is reused when the previous run had another engine or context.
+func (tr *TranslationRequestBase) manifestResults() map[int]string {
+	hits := map[int]string{}
+	if tr.Previous == nil {
+		return hits
+	}
+	if tr.Previous.Context != tr.manifestContext() {
+		tr.Cmd.PrintErrf("The previous translation used another engine, model, prompt, glossary, register, show bible, examples or length limits, translating every cue")
+		return hits
+	}
+	previous := map[string]string{}
+	for _, cue := range tr.Previous.Cues {
+		previous[cue.Hash] = cue.Translation
+	}
+	for i, item := range tr.Subtitles.Items {
+		source, _ := taggedText(item, nil)
+		if translation, ok := previous[cueHash(source)]; ok {
+			hits[i] = translation
+		}
+	}
+	if len(hits) > 0 {
+		tr.Cmd.Printf("%d of %d cues unchanged since the previous translation", len(hits), len(tr.Subtitles.Items))
+	}
+	return hits
+}
+
+// reusedResults returns the translations that do not need the engine: the
+// unchanged cues of the previous translation and the translation memory
+// matches, by index of the source text. The unchanged cues are final text,
+// with their do-not-translate spans in place.
+func (tr *TranslationRequestBase) reusedResults() map[int]string {
+	tr.reused = map[int]bool{}
+	hits := tr.manifestResults()
+	for i := range hits {
+		tr.reused[i] = true
+	}
+	for i, text := range tr.memoryResults(hits) {
+		hits[i] = text
+	}
+	return hits
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"path"
+	"testing"
+	"time"
+
+	"github.com/asticode/go-astisub"
+	"github.com/spf13/cobra"
+	"github.com/stovak/gpt-subtitles/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/language"
+)
+
+func TestGPTTranslateRequest_Translate_manifest(t *testing.T) {
+	var prompts []string
+	newFakeOpenAI(t, func(prompt string) string {
+		prompts = append(prompts, prompt)
+		return fakeTranslation(prompt)
+	})
+	fileName := path.Join(t.TempDir(), "TestFixture1.ttml")
+	newRequest := func() *GPTTranslationRequest {
+		tr, err := NewGPTTranslationRequestFromFile(
+			path.Join(util.GetRoot(), "test-fixtures", "TestFixture1.ttml"),
+			"en", "es", &cobra.Command{})
+		assert.NoError(t, err, "NewGPTTranslationRequestFromFile()")
+		gpt := tr.(*GPTTranslationRequest)
+		assert.NoError(t, gpt.Parse(), "Parse()")
+		gpt.SubtitleFileName = fileName
+		gpt.Subtitles.Items[3].Lines = []astisub.Line{{Items: []astisub.LineItem{{Text: "Ask [[Walter]] now"}}}}
+		return gpt
+	}
+
+	first := newRequest()
+	previous, err := LoadManifest(first.ManifestFileName())
+	assert.NoError(t, err, "LoadManifest()")
+	assert.Nil(t, previous, "no manifest before the first translation")
+	assert.NoError(t, first.Translate(), "Translate()")
+	translated, err := first.GetTranslated()
+	assert.NoError(t, err, "GetTranslated()")
+	translated.Items[1].Lines[0].Items[0].Text = "Sí."
+	assert.NoError(t, first.WriteManifest(translated), "WriteManifest()")
+	assert.Equal(t, path.Join(path.Dir(fileName), "TestFixture1_es-manifest.json"), first.ManifestFileName())
+	assert.Len(t, prompts, 2)
+
+	edited := newRequest()
+	edited.Previous, err = LoadManifest(edited.ManifestFileName())
+	assert.NoError(t, err, "LoadManifest()")
+	assert.Len(t, edited.Previous.Cues, 174)
+	edited.Subtitles.Items[9].Lines[0].Items[0].Text = "Where is my lawyer?"
+	edited.Subtitles.Items[2].StartAt += 500 * time.Millisecond
+	assert.NoError(t, edited.Translate(), "Translate()")
+	assert.Len(t, prompts, 3, "only the batch with the changed cue is sent")
+	assert.Contains(t, prompts[2], "\n\nWhere is my lawyer?\n\n")
+	retranslated, err := edited.GetTranslated()
+	assert.NoError(t, err, "GetTranslated()")
+	assert.Equal(t, "ES Where is my lawyer?", retranslated.Items[9].String())
+	assert.Equal(t, "Sí.", retranslated.Items[1].String(), "the final translation is carried over")
+	assert.Equal(t, "ES Ask Walter now", retranslated.Items[3].String(), "do-not-translate spans are not restored twice")
+	assert.Equal(t, edited.Subtitles.Items[2].StartAt, retranslated.Items[2].StartAt, "timing comes from the edited source")
+}
+
+func TestTranslationRequestBase_manifestResults_context(t *testing.T) {
+	tr := &TranslationRequestBase{
+		SubtitleFileName: path.Join(util.GetRoot(), "test-fixtures", "TestFixture1.ttml"),
+		Cmd:              &cobra.Command{},
+		SourceLanguage:   language.English,
+		TargetLanguage:   language.Spanish,
+		Usage:            Usage{Engine: "gpt", Model: "gpt-4"},
+	}
+	assert.NoError(t, tr.Parse(), "Parse()")
+	tr.Previous = &Manifest{
+		Engine:  "gpt",
+		Context: tr.manifestContext(),
+		Cues:    []ManifestCue{{Hash: cueHash("Yes."), Source: "Yes.", Translation: "Sí."}},
+	}
+	assert.Equal(t, map[int]string{1: "Sí."}, tr.manifestResults())
+
+	tr.Glossary = []GlossaryTerm{{Source: "lawyer", Target: "abogada"}}
+	assert.Empty(t, tr.manifestResults(), "a translation with another glossary is not reused")
+	tr.Glossary = nil
+	tr.Length = &LengthLimits{CharsPerSecond: 17}
+	assert.Empty(t, tr.manifestResults(), "a translation with other length limits is not reused")
+	tr.Length = nil
+	tr.prompt = "edited"
+	assert.Empty(t, tr.manifestResults(), "a translation of another prompt template is not reused")
+	tr.prompt = ""
+	assert.Len(t, tr.manifestResults(), 1)
+	tr.Usage.Engine = "google"
+	assert.Empty(t, tr.manifestResults(), "a translation of another engine is not reused")
+}
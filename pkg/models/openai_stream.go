@@ -0,0 +1,160 @@
+package models
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/ayush6624/go-chatgpt"
+)
+
+const openAIURL = "https://api.openai.com/v1"
+
+const (
+	// chatConnectTimeout bounds connecting to the API
+	chatConnectTimeout = 30 * time.Second
+	// chatHeaderTimeout bounds waiting for the response headers once the request is sent
+	chatHeaderTimeout = 2 * time.Minute
+	// chatIdleTimeout is how long the stream may go without data before it is given up as stalled
+	chatIdleTimeout = time.Minute
+)
+
+// chatStream sends chat completion requests to OpenAI with streaming enabled
+// so the response can be processed while it is being generated
+type chatStream struct {
+	apiKey      string
+	baseURL     string
+	client      *http.Client
+	idleTimeout time.Duration
+}
+
+type chatStreamRequest struct {
+	*chatgpt.ChatCompletionRequest
+	Stream        bool              `json:"stream"`
+	StreamOptions chatStreamOptions `json:"stream_options"`
+}
+
+type chatStreamOptions struct {
+	// IncludeUsage asks for a final chunk carrying the token usage of the request
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type chatStreamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *chatgpt.ChatResponseUsage `json:"usage"`
+}
+
+// newChatStream creates a stream client from OPENAI_API_KEY; OPENAI_BASE_URL
+// overrides the API endpoint
+func newChatStream() (*chatStream, error) {
+	key := os.Getenv("OPENAI_API_KEY")
+	if key == "" {
+		return nil, fmt.Errorf("OPENAI_API_KEY environment variable not set")
+	}
+	baseURL := os.Getenv("OPENAI_BASE_URL")
+	if baseURL == "" {
+		baseURL = openAIURL
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: chatConnectTimeout}).DialContext
+	transport.ResponseHeaderTimeout = chatHeaderTimeout
+	return &chatStream{
+		apiKey:      key,
+		baseURL:     strings.TrimSuffix(baseURL, "/"),
+		client:      &http.Client{Transport: transport},
+		idleTimeout: chatIdleTimeout,
+	}, nil
+}
+
+// Complete sends the request and calls onContent with the content received so
+// far every time a new fragment arrives. Returning an error from onContent
+// aborts the request, as does the stream going without data for longer than
+// the idle timeout. The complete content and the token usage are returned.
+func (c *chatStream) Complete(ctx context.Context, req *chatgpt.ChatCompletionRequest, onContent func(content string) error) (string, chatgpt.ChatResponseUsage, error) {
+	var usage chatgpt.ChatResponseUsage
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	body, err := json.Marshal(chatStreamRequest{
+		ChatCompletionRequest: req,
+		Stream:                true,
+		StreamOptions:         chatStreamOptions{IncludeUsage: true},
+	})
+	if err != nil {
+		return "", usage, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return "", usage, err
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+c.apiKey)
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "text/event-stream")
+	res, err := c.client.Do(httpReq)
+	if err != nil {
+		return "", usage, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		buf := new(bytes.Buffer)
+		_, _ = buf.ReadFrom(res.Body)
+		return "", usage, fmt.Errorf("api request failed: status Code: %d %s Message: %s", res.StatusCode, res.Status, buf.String())
+	}
+
+	// a stalled stream is cancelled, which fails the read that is waiting for it
+	var stalled atomic.Bool
+	idle := time.AfterFunc(c.idleTimeout, func() {
+		stalled.Store(true)
+		cancel()
+	})
+	defer idle.Stop()
+
+	content := new(strings.Builder)
+	scanner := bufio.NewScanner(res.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		idle.Reset(c.idleTimeout)
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			break
+		}
+		var chunk chatStreamChunk
+		err = json.Unmarshal([]byte(data), &chunk)
+		if err != nil {
+			return content.String(), usage, fmt.Errorf("decoding stream chunk: %w", err)
+		}
+		if chunk.Usage != nil {
+			usage = *chunk.Usage
+		}
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			continue
+		}
+		content.WriteString(chunk.Choices[0].Delta.Content)
+		if onContent != nil {
+			err = onContent(content.String())
+			if err != nil {
+				return content.String(), usage, err
+			}
+		}
+	}
+	if stalled.Load() {
+		return content.String(), usage, fmt.Errorf("no data from the stream for %s", c.idleTimeout)
+	}
+	return content.String(), usage, scanner.Err()
+}
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ayush6624/go-chatgpt"
+	"github.com/stretchr/testify/assert"
+)
+
+// newFakeOpenAI starts a server that streams reply(prompt) back in small chunks
+func newFakeOpenAI(t *testing.T, reply func(prompt string) string) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req chatStreamRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		assert.NoError(t, err, "decoding request")
+		assert.True(t, req.Stream, "stream not requested")
+		w.Header().Set("Content-Type", "text/event-stream")
+		content := []rune(reply(req.Messages[0].Content))
+		for i := 0; i < len(content); i += 7 {
+			chunk, _ := json.Marshal(map[string]any{
+				"choices": []map[string]any{{"delta": map[string]string{"content": string(content[i:min(i+7, len(content))])}}},
+			})
+			_, _ = fmt.Fprintf(w, "data: %s\n\n", chunk)
+		}
+		usage, _ := json.Marshal(map[string]any{
+			"choices": []any{},
+			"usage":   map[string]int{"prompt_tokens": 100, "completion_tokens": len(content), "total_tokens": 100 + len(content)},
+		})
+		_, _ = fmt.Fprintf(w, "data: %s\n\n", usage)
+		_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("OPENAI_API_KEY", "test")
+	t.Setenv("OPENAI_BASE_URL", server.URL)
+}
+
+// fakeTranslation "translates" the batch in the prompt by prefixing every line
+func fakeTranslation(prompt string) string {
+	parts := strings.Split(prompt, "===")
+	lines := strings.Split(strings.TrimSpace(parts[len(parts)-2]), "|")
+	for i := range lines {
+		lines[i] = "ES " + lines[i]
+	}
+	return strings.Join(lines, "|")
+}
+
+func TestChatStream_Complete(t *testing.T) {
+	newFakeOpenAI(t, func(prompt string) string {
+		return "Sí.|Sí, señor.|¿Cómo estás?"
+	})
+	stream, err := newChatStream()
+	assert.NoError(t, err, "newChatStream()")
+	var updates []string
+	content, usage, err := stream.Complete(context.Background(), &chatgpt.ChatCompletionRequest{
+		Model:    chatgpt.GPT4,
+		Messages: []chatgpt.ChatMessage{{Role: chatgpt.ChatGPTModelRoleSystem, Content: "prompt"}},
+	}, func(content string) error {
+		updates = append(updates, content)
+		return nil
+	})
+	assert.NoError(t, err, "Complete()")
+	assert.Equal(t, "Sí.|Sí, señor.|¿Cómo estás?", content)
+	assert.Greater(t, len(updates), 1, "content was not streamed")
+	assert.Equal(t, 100, usage.Prompt_Tokens)
+
+	_, _, err = stream.Complete(context.Background(), &chatgpt.ChatCompletionRequest{
+		Model:    chatgpt.GPT4,
+		Messages: []chatgpt.ChatMessage{{Role: chatgpt.ChatGPTModelRoleSystem, Content: "prompt"}},
+	}, func(content string) error {
+		return fmt.Errorf("abort")
+	})
+	assert.EqualError(t, err, "abort")
+}
+
+func TestChatStream_Complete_stalled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = fmt.Fprint(w, `data: {"choices": [{"delta": {"content": "Sí."}}]}`+"\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("OPENAI_API_KEY", "test")
+	t.Setenv("OPENAI_BASE_URL", server.URL)
+	stream, err := newChatStream()
+	assert.NoError(t, err, "newChatStream()")
+	stream.idleTimeout = 100 * time.Millisecond
+
+	content, _, err := stream.Complete(context.Background(), &chatgpt.ChatCompletionRequest{
+		Model:    chatgpt.GPT4,
+		Messages: []chatgpt.ChatMessage{{Role: chatgpt.ChatGPTModelRoleSystem, Content: "prompt"}},
+	}, nil)
+	assert.EqualError(t, err, "no data from the stream for 100ms")
+	assert.Equal(t, "Sí.", content, "the content received before the stall is returned")
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/asticode/go-astisub"
+	"golang.org/x/text/language"
+)
+
+// newTranslated returns empty subtitles with the metadata, regions and
+// styles of the source, for the translated cues to be added to
+func (tr *TranslationRequestBase) newTranslated() *astisub.Subtitles {
+	toReturn := astisub.NewSubtitles()
+	if tr.Subtitles.Metadata != nil {
+		metadata := *tr.Subtitles.Metadata
+		// the language is written by WriteTTML, astisub only knows a handful
+		metadata.Language = ""
+		toReturn.Metadata = &metadata
+	}
+	for id, region := range tr.Subtitles.Regions {
+		toReturn.Regions[id] = region
+	}
+	for id, style := range tr.Subtitles.Styles {
+		toReturn.Styles[id] = style
+	}
+	return toReturn
+}
+
+// translatedItem returns the translation of a source cue with the timing,
+// region, style and placement of the source
+func (tr *TranslationRequestBase) translatedItem(source *astisub.Item, text string) *astisub.Item {
+	return &astisub.Item{
+		Comments:    source.Comments,
+		Index:       source.Index,
+		StartAt:     source.StartAt,
+		EndAt:       source.EndAt,
+		InlineStyle: source.InlineStyle,
+		Region:      source.Region,
+		Style:       source.Style,
+		Lines:       tr.cueLines(source, text),
+	}
+}
+
+// ttmlRoot matches the start tag of a TTML document
+var ttmlRoot = regexp.MustCompile(`<(?:[\w.-]+:)?tt[\s>][^>]*>`)
+
+// ttmlLang matches the xml:lang attribute of a start tag, in either quote style
+var ttmlLang = regexp.MustCompile(`(\sxml:lang\s*=\s*)(?:"[^"]*"|'[^']*')`)
+
+// ttmlBody matches the start tag of the body of a TTML document
+var ttmlBody = regexp.MustCompile(`<(?:[\w.-]+:)?body[\s>/]`)
+
+// startTag matches any start tag
+var startTag = regexp.MustCompile(`<[^!?/][^>]*>`)
+
+// WriteTTML writes subtitles as TTML with the document language (xml:lang)
+// set to lang
+func WriteTTML(w io.Writer, subtitles *astisub.Subtitles, lang language.Tag) error {
+	buf := new(strings.Builder)
+	err := subtitles.WriteToTTML(buf)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, setTTMLLang(buf.String(), lang))
+	return err
+}
+
+// setTTMLLang sets the xml:lang attribute of the root element of a TTML
+// document, in place when it has one. The elements of the body that
+// override the language of the document get lang as well.
+func setTTMLLang(document string, lang language.Tag) string {
+	root := ttmlRoot.FindStringIndex(document)
+	if root == nil {
+		return document
+	}
+	value := `"` + lang.String() + `"`
+	tag := document[root[0]:root[1]]
+	if ttmlLang.MatchString(tag) {
+		tag = ttmlLang.ReplaceAllString(tag, "${1}"+value)
+	} else {
+		name := strings.IndexAny(tag, " \t\r\n>")
+		tag = tag[:name] + " xml:lang=" + value + tag[name:]
+	}
+	document = document[:root[0]] + tag + document[root[1]:]
+	body := ttmlBody.FindStringIndex(document)
+	if body == nil {
+		return document
+	}
+	return document[:body[0]] + startTag.ReplaceAllStringFunc(document[body[0]:], func(tag string) string {
+		return ttmlLang.ReplaceAllString(tag, "${1}"+value)
+	})
+}
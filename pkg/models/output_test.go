@@ -0,0 +1,67 @@
+package models
+
+import (
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stovak/gpt-subtitles/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/language"
+)
+
+func TestGPTTranslateRequest_GetTranslated_layout(t *testing.T) {
+	newFakeOpenAI(t, fakeTranslation)
+	tr, err := NewGPTTranslationRequestFromFile(
+		path.Join(util.GetRoot(), "test-fixtures", "TestFixture1.ttml"),
+		"en", "es", &cobra.Command{})
+	assert.NoError(t, err, "NewGPTTranslationRequestFromFile()")
+	source := tr.GetBase().Subtitles
+	source.Items[9].Region = source.Regions["topCenter"]
+	err = tr.Translate()
+	assert.NoError(t, err, "Translate()")
+	translated, err := tr.GetTranslated()
+	assert.NoError(t, err, "GetTranslated()")
+	assert.Len(t, translated.Regions, 9, "the layout regions of the source")
+	assert.NotContains(t, translated.Regions, "ES", "no region is named after the target language")
+	assert.Contains(t, translated.Styles, "s0")
+	assert.Same(t, source.Regions["topCenter"], translated.Items[9].Region, "cues keep their placement")
+
+	out := new(strings.Builder)
+	err = WriteTTML(out, translated, language.MustParse("es-MX"))
+	assert.NoError(t, err, "WriteTTML()")
+	assert.Contains(t, out.String(), `xml:lang="es-MX"`)
+	assert.NotContains(t, out.String(), `xml:lang="en"`)
+	assert.Contains(t, out.String(), `xml:id="bottomCenter"`)
+	assert.Contains(t, out.String(), `region="topCenter"`)
+}
+
+func TestSetTTMLLang(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		want     string
+	}{
+		{
+			name:     "setTTMLLang-missing",
+			document: `<tt xmlns="http://www.w3.org/ns/ttml"><body/></tt>`,
+			want:     `<tt xml:lang="es" xmlns="http://www.w3.org/ns/ttml"><body/></tt>`,
+		},
+		{
+			name:     "setTTMLLang-single-quotes",
+			document: `<tt xmlns="http://www.w3.org/ns/ttml" xml:lang='en-US'><body/></tt>`,
+			want:     `<tt xmlns="http://www.w3.org/ns/ttml" xml:lang="es"><body/></tt>`,
+		},
+		{
+			name:     "setTTMLLang-overrides",
+			document: `<tt xml:lang="en"><head><ttm:title xml:lang="en">Pilot</ttm:title></head><body xml:lang='en'><div><p xml:lang="en-GB">Yes.</p></div></body></tt>`,
+			want:     `<tt xml:lang="es"><head><ttm:title xml:lang="en">Pilot</ttm:title></head><body xml:lang="es"><div><p xml:lang="es">Yes.</p></div></body></tt>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, setTTMLLang(tt.document, language.Spanish))
+		})
+	}
+}
@@ -0,0 +1,277 @@
+package models
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/asticode/go-astisub"
+	"golang.org/x/text/language"
+)
+
+// Patching rewrites only the text of every cue in the original document and
+// keeps everything else byte for byte: ids, attributes, namespaces, comments
+// and ordering. Deliveries are validated against the original document,
+// which re-serialising through astisub does not survive.
+
+var (
+	ttmlParagraph = regexp.MustCompile(`<((?:[\w.-]+:)?p)(\s[^>]*)?>`)
+	ttmlSpan      = regexp.MustCompile(`<((?:[\w.-]+:)?span)(\s[^>]*)?>`)
+	ttmlBreak     = regexp.MustCompile(`<(?:[\w.-]+:)?br(\s[^>]*)?/?>`)
+	webVTTTag     = regexp.MustCompile(`<(c|i|b|u|lang|ruby)([.\s][^>]*)?>`)
+	webVTTOpen    = regexp.MustCompile(`^(?:<(?:v|lang)(?:[.\s][^>]*)?>)+`)
+	webVTTWrapper = regexp.MustCompile(`<(v|lang)(?:\.[^\s>]*)?(?:\s+([^>]*))?>`)
+	webVTTClose   = regexp.MustCompile(`(?:</(?:v|lang)>)+$`)
+	xmlText       = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+)
+
+// OpenSubtitles opens a subtitle file like astisub.OpenFile, which does not
+// recognise DFXP files by their .dfxp or .xml extension
+func OpenSubtitles(fileName string) (*astisub.Subtitles, error) {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".dfxp", ".xml":
+		f, err := os.Open(fileName)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return astisub.ReadFromTTML(f)
+	default:
+		return astisub.OpenFile(fileName)
+	}
+}
+
+// PatchedFileName returns the name of the patched translation of fileName,
+// which keeps its extension, e.g. movie.vtt => movie_es.vtt
+func PatchedFileName(fileName string, variant string) string {
+	ext := filepath.Ext(fileName)
+	return strings.TrimSuffix(fileName, ext) + "_" + variant + ext
+}
+
+// CheckPatchable returns an error when fileName is not a TTML, DFXP or WebVTT
+// file, the formats a translation can be patched into
+func CheckPatchable(fileName string) error {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".ttml", ".dfxp", ".xml", ".vtt":
+		return nil
+	default:
+		return fmt.Errorf("patching is only supported for TTML, DFXP and WebVTT files, not %s", fileName)
+	}
+}
+
+// WritePatched writes the translation as a patch of the source file next to it
+func (tr *TranslationRequestBase) WritePatched(translated *astisub.Subtitles) error {
+	err := CheckPatchable(tr.SubtitleFileName)
+	if err != nil {
+		return err
+	}
+	original, err := os.ReadFile(tr.SubtitleFileName)
+	if err != nil {
+		return err
+	}
+	var patched []byte
+	var lost []int
+	switch strings.ToLower(filepath.Ext(tr.SubtitleFileName)) {
+	case ".ttml", ".dfxp", ".xml":
+		patched, lost, err = PatchTTML(original, tr.Subtitles, translated, tr.TargetLanguage)
+	default:
+		patched, lost, err = PatchWebVTT(original, tr.Subtitles, translated)
+	}
+	if err != nil {
+		return err
+	}
+	for _, i := range lost {
+		tr.Cmd.PrintErrf("Cue %d: the styling of the source could not be matched, its text is patched without styling\n", i+1)
+	}
+	fileName := PatchedFileName(tr.SubtitleFileName, tr.TargetLanguage.String())
+	tr.Cmd.Printf("Writing patched translation to %s", fileName)
+	return os.WriteFile(fileName, patched, 0644)
+}
+
+// PatchTTML replaces the content of every paragraph of a TTML or DFXP
+// document with the translated cue and sets the document language. Styled
+// spans reuse the start tags of the source spans and line breaks the source's
+// break tag. The indexes of the cues whose styling could not be matched are
+// returned.
+func PatchTTML(original []byte, source *astisub.Subtitles, translated *astisub.Subtitles, lang language.Tag) ([]byte, []int, error) {
+	document := setTTMLLang(string(original), lang)
+	var b strings.Builder
+	var lost []int
+	cue := 0
+	for {
+		start := ttmlParagraph.FindStringSubmatchIndex(document)
+		if start == nil {
+			break
+		}
+		closing := "</" + document[start[2]:start[3]] + ">"
+		if strings.HasSuffix(document[start[0]:start[1]], "/>") {
+			// an empty paragraph is not a cue
+			b.WriteString(document[:start[1]])
+			document = document[start[1]:]
+			continue
+		}
+		end := strings.Index(document[start[1]:], closing)
+		if end < 0 {
+			return nil, nil, fmt.Errorf("paragraph %d is not closed", cue+1)
+		}
+		end += start[1]
+		if cue >= len(source.Items) || cue >= len(translated.Items) {
+			return nil, nil, fmt.Errorf("the document has more paragraphs than the %d cues of the translation", len(translated.Items))
+		}
+		inner := document[start[1]:end]
+		lineBreak := "<br/>"
+		if raw := ttmlBreak.FindString(inner); raw != "" {
+			lineBreak = raw
+		}
+		text, ok := patchText(inner, ttmlSpan, source.Items[cue], translated.Items[cue], lineBreak)
+		if !ok {
+			lost = append(lost, cue)
+		}
+		b.WriteString(document[:start[1]])
+		b.WriteString(text)
+		b.WriteString(closing)
+		document = document[end+len(closing):]
+		cue++
+	}
+	b.WriteString(document)
+	if cue != len(translated.Items) {
+		return nil, nil, fmt.Errorf("the document has %d paragraphs for %d cues", cue, len(translated.Items))
+	}
+	return []byte(b.String()), lost, nil
+}
+
+// PatchWebVTT replaces the text lines of every cue of a WebVTT document with
+// the lines of the translated cue. Styled spans reuse the tags of the source
+// cue and the voice and language spans wrapping its lines are kept around the
+// translated lines. The indexes of the cues whose styling could not be matched are
+// returned.
+func PatchWebVTT(original []byte, source *astisub.Subtitles, translated *astisub.Subtitles) ([]byte, []int, error) {
+	newline := "\n"
+	if bytes.Contains(original, []byte("\r\n")) {
+		newline = "\r\n"
+	}
+	lines := strings.Split(strings.ReplaceAll(string(original), "\r\n", "\n"), "\n")
+	var out []string
+	var lost []int
+	cue := 0
+	for i := 0; i < len(lines); i++ {
+		out = append(out, lines[i])
+		if !strings.Contains(lines[i], "-->") {
+			continue
+		}
+		end := i + 1
+		for end < len(lines) && strings.TrimSpace(lines[end]) != "" {
+			end++
+		}
+		if cue >= len(source.Items) || cue >= len(translated.Items) {
+			return nil, nil, fmt.Errorf("the document has more cues than the %d cues of the translation", len(translated.Items))
+		}
+		body := slices.Clone(lines[i+1 : end])
+		opens := make([]string, len(body))
+		closes := make([]string, len(body))
+		var langs []string
+		for n, line := range body {
+			opens[n] = webVTTOpen.FindString(line)
+			for _, tag := range webVTTWrapper.FindAllStringSubmatch(opens[n], -1) {
+				if tag[1] == "lang" {
+					langs = append(langs, strings.TrimSpace(tag[2]))
+				}
+			}
+			closes[n] = webVTTClose.FindString(line[len(opens[n]):])
+			body[n] = line[len(opens[n]) : len(line)-len(closes[n])]
+		}
+		text, ok := patchText(strings.Join(body, "\n"), webVTTTag, withoutLangTags(source.Items[cue], langs), withoutLangTags(translated.Items[cue], langs), "\n")
+		if !ok {
+			lost = append(lost, cue)
+		}
+		patched := strings.Split(text, "\n")
+		if len(patched) == len(body) {
+			for n := range patched {
+				patched[n] = opens[n] + patched[n] + closes[n]
+			}
+		} else if len(body) > 0 {
+			patched[0] = opens[0] + patched[0]
+			patched[len(patched)-1] += closes[len(body)-1]
+		}
+		out = append(out, patched...)
+		i = end - 1
+		cue++
+	}
+	if cue != len(translated.Items) {
+		return nil, nil, fmt.Errorf("the document has %d cues for %d cues of the translation", cue, len(translated.Items))
+	}
+	return []byte(strings.Join(out, newline)), lost, nil
+}
+
+// withoutLangTags returns a copy of item without the WebVTT language tags of
+// langs, which wrap the lines of the cue rather than style a span of it
+func withoutLangTags(item *astisub.Item, langs []string) *astisub.Item {
+	if len(langs) == 0 {
+		return item
+	}
+	stripped := *item
+	stripped.Lines = make([]astisub.Line, len(item.Lines))
+	for i, line := range item.Lines {
+		line.Items = slices.Clone(line.Items)
+		for n, lineItem := range line.Items {
+			if lineItem.InlineStyle == nil {
+				continue
+			}
+			inline := *lineItem.InlineStyle
+			inline.WebVTTTags = slices.DeleteFunc(slices.Clone(inline.WebVTTTags), func(tag astisub.WebVTTTag) bool {
+				return tag.Name == "lang" && slices.Contains(langs, strings.TrimSpace(tag.Annotation))
+			})
+			line.Items[n].InlineStyle = &inline
+		}
+		stripped.Lines[i] = line
+	}
+	return &stripped
+}
+
+// patchText renders the lines of a translated cue in the markup of the
+// original content of the source cue, keeping its leading and trailing
+// whitespace. The distinct style tags of the original, in order, stand for
+// the styles of the source cue; when they do not match up, the text is
+// rendered without styling and false is returned.
+func patchText(original string, styleTag *regexp.Regexp, source *astisub.Item, translated *astisub.Item, lineBreak string) (string, bool) {
+	_, styles := taggedText(source, nil)
+	var opens []string
+	for _, open := range styleTag.FindAllString(original, -1) {
+		if !slices.Contains(opens, open) {
+			opens = append(opens, open)
+		}
+	}
+	matched := len(opens) == len(styles)
+	var lines []string
+	for _, line := range translated.Lines {
+		tagged, _ := taggedText(&astisub.Item{Lines: []astisub.Line{line}}, styles)
+		var b strings.Builder
+		last := 0
+		for _, tag := range styleTagPattern.FindAllStringSubmatchIndex(tagged, -1) {
+			b.WriteString(xmlText.Replace(tagged[last:tag[0]]))
+			last = tag[1]
+			n, _ := strconv.Atoi(tagged[tag[2]:tag[3]])
+			if !matched || n > len(opens) {
+				continue
+			}
+			open := opens[n-1]
+			if tagged[tag[0]+1] == '/' {
+				name := styleTag.FindStringSubmatch(open)[1]
+				b.WriteString("</" + name + ">")
+				continue
+			}
+			b.WriteString(open)
+		}
+		b.WriteString(xmlText.Replace(tagged[last:]))
+		lines = append(lines, b.String())
+	}
+	trimmed := strings.TrimSpace(original)
+	lead := original[:strings.Index(original, trimmed)]
+	trail := original[len(lead)+len(trimmed):]
+	return lead + strings.Join(lines, lineBreak) + trail, matched || len(styles) == 0
+}
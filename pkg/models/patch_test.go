@@ -0,0 +1,150 @@
+package models
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stovak/gpt-subtitles/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/language"
+)
+
+func TestTranslationRequestBase_WritePatched(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileName string
+		original string
+		want     string
+	}{
+		{
+			name:     "WritePatched-ttml",
+			fileName: "episode.ttml",
+			original: `<?xml version="1.0" encoding="utf-8"?>
+<tt xmlns="http://www.w3.org/ns/ttml" xmlns:ttp="http://www.w3.org/ns/ttml#parameter" ttp:timeBase="media" xmlns:tts="http://www.w3.org/ns/ttml#styling" xml:lang="en">
+  <!-- delivered by the broadcaster -->
+  <body>
+    <div>
+      <p xml:id="c1" begin="1s" end="2s">Where's my <span tts:fontStyle="italic">lawyer</span>?</p>
+      <p xml:id="c2" begin="3s" end="4s" tts:textAlign="center">
+        On the way.<br />
+        We can wait.
+      </p>
+    </div>
+  </body>
+</tt>
+`,
+			want: `<?xml version="1.0" encoding="utf-8"?>
+<tt xmlns="http://www.w3.org/ns/ttml" xmlns:ttp="http://www.w3.org/ns/ttml#parameter" ttp:timeBase="media" xmlns:tts="http://www.w3.org/ns/ttml#styling" xml:lang="es">
+  <!-- delivered by the broadcaster -->
+  <body>
+    <div>
+      <p xml:id="c1" begin="1s" end="2s">ES Where's my <span tts:fontStyle="italic">lawyer</span>?</p>
+      <p xml:id="c2" begin="3s" end="4s" tts:textAlign="center">
+        ES On the way.<br />We can wait.
+      </p>
+    </div>
+  </body>
+</tt>
+`,
+		},
+		{
+			name:     "WritePatched-dfxp",
+			fileName: "episode.dfxp",
+			original: `<tt xmlns="http://www.w3.org/ns/ttml" xml:lang="en"><body><div><p begin="00:00:01.000" end="00:00:02.000">Legally &amp; morally.</p></div></body></tt>`,
+			want:     `<tt xmlns="http://www.w3.org/ns/ttml" xml:lang="es"><body><div><p begin="00:00:01.000" end="00:00:02.000">ES Legally &amp; morally.</p></div></body></tt>`,
+		},
+		{
+			name:     "WritePatched-vtt",
+			fileName: "episode.vtt",
+			original: `WEBVTT
+
+NOTE kept as it is
+
+c1
+00:00:01.000 --> 00:00:02.000 align:start line:10%
+Where's my <i>lawyer</i>?
+
+c2
+00:00:03.000 --> 00:00:04.000
+On the way.
+We can wait.
+`,
+			want: `WEBVTT
+
+NOTE kept as it is
+
+c1
+00:00:01.000 --> 00:00:02.000 align:start line:10%
+ES Where's my <i>lawyer</i>?
+
+c2
+00:00:03.000 --> 00:00:04.000
+ES On the way.
+We can wait.
+`,
+		},
+		{
+			name:     "WritePatched-vtt-voices",
+			fileName: "voices.vtt",
+			original: `WEBVTT
+
+00:00:01.000 --> 00:00:02.000
+<v Bob>Hello there</v>
+
+00:00:03.000 --> 00:00:04.000
+<v Ann>Hi <i>you</i></v>
+
+00:00:05.000 --> 00:00:06.000
+<lang en-GB>Cheerio, <i>mate</i></lang>
+`,
+			want: `WEBVTT
+
+00:00:01.000 --> 00:00:02.000
+<v Bob>ES Hello there</v>
+
+00:00:03.000 --> 00:00:04.000
+<v Ann>ES Hi <i>you</i></v>
+
+00:00:05.000 --> 00:00:06.000
+<lang en-GB>ES Cheerio, <i>mate</i></lang>
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newFakeOpenAI(t, fakeTranslation)
+			fileName := path.Join(t.TempDir(), tt.fileName)
+			assert.NoError(t, os.WriteFile(fileName, []byte(tt.original), 0644), "WriteFile()")
+			tr, err := NewGPTTranslationRequestFromFile(fileName, "en", "es", &cobra.Command{})
+			assert.NoError(t, err, "NewGPTTranslationRequestFromFile()")
+			assert.NoError(t, tr.Translate(), "Translate()")
+			translated, err := tr.GetTranslated()
+			assert.NoError(t, err, "GetTranslated()")
+			assert.NoError(t, tr.GetBase().WritePatched(translated), "WritePatched()")
+			patched, err := os.ReadFile(PatchedFileName(fileName, "es"))
+			assert.NoError(t, err, "ReadFile()")
+			assert.Equal(t, tt.want, string(patched))
+		})
+	}
+}
+
+func TestPatchTTML_mismatch(t *testing.T) {
+	fileName := path.Join(util.GetRoot(), "test-fixtures", "TestFixture1.ttml")
+	source, err := OpenSubtitles(fileName)
+	assert.NoError(t, err, "OpenSubtitles()")
+	original, err := os.ReadFile(fileName)
+	assert.NoError(t, err, "ReadFile()")
+	translated := *source
+	translated.Items = source.Items[:10]
+	_, _, err = PatchTTML(original, source, &translated, language.Spanish)
+	assert.ErrorContains(t, err, "more paragraphs than the 10 cues")
+}
+
+func TestCheckPatchable(t *testing.T) {
+	assert.NoError(t, CheckPatchable("episode.ttml"))
+	assert.NoError(t, CheckPatchable("episode.DFXP"))
+	assert.NoError(t, CheckPatchable("episode.vtt"))
+	assert.ErrorContains(t, CheckPatchable("episode.srt"), "only supported for TTML, DFXP and WebVTT")
+}
@@ -0,0 +1,127 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/asticode/go-astisub"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+// QualityIssues are the issue categories a quality estimate may report
+var QualityIssues = []string{"none", "mistranslation", "omission", "grammar", "style", "terminology", "length", "untranslated"}
+
+// QualityScore is the estimated quality of one translated cue, from 0 (unusable) to 100 (perfect)
+type QualityScore struct {
+	Cue         int    `json:"cue"`
+	StartAt     string `json:"start_at"`
+	EndAt       string `json:"end_at"`
+	Source      string `json:"source"`
+	Translation string `json:"translation"`
+	Score       int    `json:"score"`
+	Issue       string `json:"issue"`
+}
+
+// QualityEstimator is an LLM pass that scores every cue of a finished
+// translation so reviewers only need to look at the low scoring ones
+type QualityEstimator struct {
+	cuePass
+	// Threshold is the score below which a cue is flagged
+	Threshold int
+}
+
+// NewQualityEstimator creates an estimator prompting with the named template from the templates directory
+func NewQualityEstimator(templateName string, threshold int) *QualityEstimator {
+	return &QualityEstimator{
+		cuePass:   newCuePass(templateName, "quality"),
+		Threshold: threshold,
+	}
+}
+
+// Estimate sends the source and translated cues to the LLM in batches and
+// returns a score for every cue it rated
+func (q *QualityEstimator) Estimate(tr *TranslationRequestBase, translated *astisub.Subtitles) ([]QualityScore, error) {
+	var scores []QualityScore
+	err := q.run(tr, translated, allCues(tr, translated), "Scoring", func(content string, batch []int) error {
+		batchScores, err := parseQualityScores(content, batch)
+		if err != nil {
+			return err
+		}
+		for _, score := range batchScores {
+			item := translated.Items[score.Cue-1]
+			score.StartAt = FormatTimecode(item.StartAt)
+			score.EndAt = FormatTimecode(item.EndAt)
+			score.Source = cueText(tr.Subtitles.Items[score.Cue-1])
+			score.Translation = cueText(item)
+			scores = append(scores, score)
+		}
+		return nil
+	})
+	return scores, err
+}
+
+// Flagged returns the scores below the threshold
+func (q *QualityEstimator) Flagged(scores []QualityScore) []QualityScore {
+	var toReturn []QualityScore
+	for _, score := range scores {
+		if score.Score < q.Threshold {
+			toReturn = append(toReturn, score)
+		}
+	}
+	return toReturn
+}
+
+// parseQualityScores reads the JSON array of scores returned by the LLM,
+// which may be wrapped in a markdown code block. Cue numbers outside the
+// batch, scores outside 0-100 and unknown issues are rejected.
+func parseQualityScores(content string, batch []int) ([]QualityScore, error) {
+	var scores []QualityScore
+	err := json.Unmarshal([]byte(unfenceJSON(content)), &scores)
+	if err != nil {
+		return nil, fmt.Errorf("quality response is not a JSON array of scores: %w", err)
+	}
+	for i, score := range scores {
+		err = checkCueNumber("score", score.Cue, batch)
+		if err != nil {
+			return nil, err
+		}
+		if score.Score < 0 || score.Score > 100 {
+			return nil, fmt.Errorf("quality score %d of cue %d is not between 0 and 100", score.Score, score.Cue)
+		}
+		scores[i].Issue = strings.ToLower(strings.TrimSpace(score.Issue))
+		if scores[i].Issue == "" {
+			scores[i].Issue = "none"
+		}
+		if !slices.Contains(QualityIssues, scores[i].Issue) {
+			return nil, fmt.Errorf("quality issue %q of cue %d is not one of %s", score.Issue, score.Cue, strings.Join(QualityIssues, ", "))
+		}
+	}
+	return scores, nil
+}
+
+// QualityReport renders the flagged cues as a table with their timecodes
+func QualityReport(flagged []QualityScore, total int) string {
+	t := table.NewWriter()
+	t.AppendHeader(table.Row{"#", "Start", "End", "Source", "Translation", "Score", "Issue"})
+	for _, s := range flagged {
+		t.AppendRow(table.Row{s.Cue, s.StartAt, s.EndAt, s.Source, s.Translation, s.Score, s.Issue})
+	}
+	t.AppendFooter(table.Row{"", "", "", "", "Flagged", fmt.Sprintf("%d/%d", len(flagged), total), ""})
+	return t.Render()
+}
+
+// WriteQualityScores writes the scores of every cue as JSON next to the
+// source file, e.g. movie_es-quality.json
+func (tr *TranslationRequestBase) WriteQualityScores(scores []QualityScore) error {
+	contents, err := json.MarshalIndent(scores, "", "    ")
+	if err != nil {
+		return err
+	}
+	fileName := strings.TrimSuffix(tr.SubtitleFileName, filepath.Ext(tr.SubtitleFileName)) + fmt.Sprintf("_%s-quality.json", tr.TargetLanguage)
+	tr.Cmd.Printf("Writing quality scores to %s", fileName)
+	return os.WriteFile(fileName, contents, 0644)
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stovak/gpt-subtitles/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/language"
+)
+
+func TestParseQualityScores(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []QualityScore
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name:    "Scores-fenced",
+			content: "```json\n[{\"cue\": 51, \"score\": 92, \"issue\": \"None\"}, {\"cue\": 52, \"score\": 40}]\n```",
+			want:    []QualityScore{{Cue: 51, Score: 92, Issue: "none"}, {Cue: 52, Score: 40, Issue: "none"}},
+			wantErr: assert.NoError,
+		},
+		{name: "Scores-out-of-batch", content: `[{"cue": 3, "score": 90, "issue": "none"}]`, wantErr: assert.Error},
+		{name: "Scores-out-of-range", content: `[{"cue": 51, "score": 120, "issue": "none"}]`, wantErr: assert.Error},
+		{name: "Scores-unknown-issue", content: `[{"cue": 51, "score": 20, "issue": "vibes"}]`, wantErr: assert.Error},
+		{name: "Scores-not-json", content: "All good!", wantErr: assert.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseQualityScores(tt.content, cueIndexes(50, 100))
+			tt.wantErr(t, err, "parseQualityScores(%s)", tt.content)
+			if tt.want != nil {
+				assert.Equalf(t, tt.want, got, "parseQualityScores(%s)", tt.content)
+			}
+		})
+	}
+}
+
+func TestQualityEstimator_Estimate(t *testing.T) {
+	newFakeOpenAI(t, func(prompt string) string {
+		if strings.Contains(prompt, "\n2. Yes.\n") {
+			return `[{"cue": 1, "score": 95, "issue": "none"}, {"cue": 2, "score": 10, "issue": "untranslated"}, {"cue": 10, "score": 65, "issue": "style"}]`
+		}
+		return "[]"
+	})
+	tr := &TranslationRequestBase{
+		SubtitleFileName: path.Join(util.GetRoot(), "test-fixtures", "TestFixture1.ttml"),
+		Cmd:              &cobra.Command{},
+		SourceLanguage:   language.English,
+		TargetLanguage:   language.Spanish,
+	}
+	assert.NoError(t, tr.Parse(), "Parse()")
+	translated := translatedCopy(tr, func(s string) string { return "ES " + s })
+	translated.Items[1].Lines[0].Items[0].Text = "Yes."
+
+	quality := NewQualityEstimator("gpt-quality-request.tmpl", 70)
+	scores, err := quality.Estimate(tr, translated)
+	assert.NoError(t, err, "Estimate()")
+	assert.Len(t, scores, 3)
+	assert.Equal(t, 4, quality.Usage.Requests, "174 cues are scored in batches of 50")
+
+	flagged := quality.Flagged(scores)
+	assert.Len(t, flagged, 2)
+	assert.Equal(t, QualityScore{Cue: 2, StartAt: flagged[0].StartAt, EndAt: flagged[0].EndAt, Source: "Yes.", Translation: "Yes.", Score: 10, Issue: "untranslated"}, flagged[0])
+	assert.Equal(t, "00:02:46.375", flagged[1].StartAt)
+
+	report := QualityReport(flagged, len(translated.Items))
+	assert.Contains(t, report, "Where's my lawyer?")
+	assert.Contains(t, report, "2/174")
+	assert.NotContains(t, report, "95")
+}
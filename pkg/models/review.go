@@ -0,0 +1,97 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/asticode/go-astisub"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+// Edit is a correction the reviewer applied to a translated cue
+type Edit struct {
+	Index  int
+	Source string
+	Before string
+	After  string
+	Reason string
+}
+
+// Reviewer is an LLM pass over a finished translation that returns
+// corrections for the cues it finds wrong. It works on the output of any
+// engine.
+type Reviewer struct {
+	cuePass
+}
+
+// NewReviewer creates a reviewer prompting with the named template from the templates directory
+func NewReviewer(templateName string) *Reviewer {
+	return &Reviewer{
+		cuePass: newCuePass(templateName, "review"),
+	}
+}
+
+// Review sends the source and translated cues to the LLM in batches and
+// applies the corrections it returns to translated
+func (r *Reviewer) Review(tr *TranslationRequestBase, translated *astisub.Subtitles) ([]Edit, error) {
+	var edits []Edit
+	err := r.run(tr, translated, allCues(tr, translated), "Reviewing", func(content string, batch []int) error {
+		batchEdits, err := parseEdits(content, batch)
+		if err != nil {
+			return err
+		}
+		for _, edit := range batchEdits {
+			edit.Source, edit.Before = taggedCue(tr.Subtitles.Items[edit.Index], translated.Items[edit.Index])
+			if edit.After == edit.Before {
+				continue
+			}
+			translated.Items[edit.Index].Lines = tr.cueLines(tr.Subtitles.Items[edit.Index], edit.After)
+			edits = append(edits, edit)
+		}
+		return nil
+	})
+	return edits, err
+}
+
+// parseEdits reads the JSON array of corrections returned by the LLM, which
+// may be wrapped in a markdown code block. Cue numbers outside the batch are
+// rejected.
+func parseEdits(content string, batch []int) ([]Edit, error) {
+	content = unfenceJSON(content)
+	var corrections []struct {
+		Cue         int    `json:"cue"`
+		Translation string `json:"translation"`
+		Reason      string `json:"reason"`
+	}
+	err := json.Unmarshal([]byte(content), &corrections)
+	if err != nil {
+		return nil, fmt.Errorf("review response is not a JSON array of corrections: %w", err)
+	}
+	var toReturn []Edit
+	for _, c := range corrections {
+		err = checkCueNumber("correction", c.Cue, batch)
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(c.Translation) == "" {
+			continue
+		}
+		toReturn = append(toReturn, Edit{
+			Index:  c.Cue - 1,
+			After:  strings.TrimSpace(c.Translation),
+			Reason: c.Reason,
+		})
+	}
+	return toReturn, nil
+}
+
+// WriteEditLog writes a table of the edits a review pass applied next to the source file
+func (tr *TranslationRequestBase) WriteEditLog(variant string, edits []Edit) error {
+	t := table.NewWriter()
+	t.AppendHeader(table.Row{"#", "Source", "Before", "After", "Reason"})
+	for _, e := range edits {
+		t.AppendRow(table.Row{e.Index + 1, e.Source, e.Before, e.After, e.Reason})
+	}
+	return tr.WriteReport(fmt.Sprintf("%s-%s", tr.TargetLanguage, variant), t.Render())
+}
@@ -0,0 +1,100 @@
+package models
+
+import (
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/asticode/go-astisub"
+	"github.com/spf13/cobra"
+	"github.com/stovak/gpt-subtitles/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/language"
+)
+
+func TestParseEdits(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []Edit
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{name: "Edits-none", content: "[]", want: nil, wantErr: assert.NoError},
+		{
+			name:    "Edits-fenced",
+			content: "```json\n[{\"cue\": 52, \"translation\": \"Sí.\", \"reason\": \"more natural\"}]\n```",
+			want:    []Edit{{Index: 51, After: "Sí.", Reason: "more natural"}},
+			wantErr: assert.NoError,
+		},
+		{name: "Edits-out-of-batch", content: `[{"cue": 3, "translation": "Sí."}]`, wantErr: assert.Error},
+		{name: "Edits-not-json", content: "Everything looks fine!", wantErr: assert.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseEdits(tt.content, cueIndexes(50, 100))
+			tt.wantErr(t, err, "parseEdits(%s)", tt.content)
+			assert.Equalf(t, tt.want, got, "parseEdits(%s)", tt.content)
+		})
+	}
+}
+
+// translatedCopy returns subtitles with the same cues as tr, each translated by translate
+func translatedCopy(tr *TranslationRequestBase, translate func(string) string) *astisub.Subtitles {
+	toReturn := astisub.NewSubtitles()
+	for _, item := range tr.Subtitles.Items {
+		translated := &astisub.Item{StartAt: item.StartAt, EndAt: item.EndAt}
+		setItemText(translated, translate(item.String()))
+		toReturn.Items = append(toReturn.Items, translated)
+	}
+	return toReturn
+}
+
+func TestReviewer_Review(t *testing.T) {
+	var prompts []string
+	newFakeOpenAI(t, func(prompt string) string {
+		prompts = append(prompts, prompt)
+		if strings.Contains(prompt, "\n2. Yes.\n") {
+			return `[{"cue": 2, "translation": "Sí.", "reason": "untranslated"}, {"cue": 3, "translation": "ES Do I know you? I feel like I know you, you. Detective don't I know him.", "reason": "unchanged"}]`
+		}
+		return "[]"
+	})
+	tr := &TranslationRequestBase{
+		SubtitleFileName: path.Join(util.GetRoot(), "test-fixtures", "TestFixture1.ttml"),
+		Cmd:              &cobra.Command{},
+		SourceLanguage:   language.English,
+		TargetLanguage:   language.Spanish,
+	}
+	assert.NoError(t, tr.Parse(), "Parse()")
+	translated := translatedCopy(tr, func(s string) string { return "ES " + s })
+	translated.Items[1].Lines[0].Items[0].Text = "Yes."
+
+	reviewer := NewReviewer("gpt-review-request.tmpl")
+	edits, err := reviewer.Review(tr, translated)
+	assert.NoError(t, err, "Review()")
+	assert.Len(t, prompts, 4, "174 cues are reviewed in batches of 50")
+	assert.Equal(t, []Edit{{Index: 1, Source: "Yes.", Before: "Yes.", After: "Sí.", Reason: "untranslated"}}, edits)
+	assert.Equal(t, "Sí.", translated.Items[1].String())
+	assert.Equal(t, 4, reviewer.Usage.Requests)
+}
+
+// cueIndexes returns the indexes start to end-1
+func cueIndexes(start int, end int) []int {
+	var toReturn []int
+	for i := start; i < end; i++ {
+		toReturn = append(toReturn, i)
+	}
+	return toReturn
+}
+
+// setItemText replaces the text of a cue with a single line
+func setItemText(item *astisub.Item, text string) {
+	item.Lines = []astisub.Line{
+		{
+			Items: []astisub.LineItem{
+				{
+					Text: text,
+				},
+			},
+		},
+	}
+}
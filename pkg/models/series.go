@@ -0,0 +1,193 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/asticode/go-astisub"
+	"github.com/stovak/gpt-subtitles/pkg/util"
+)
+
+// SeriesLine is a line of dialogue of a series with the episodes it was
+// spoken in and the translation it was first given in each language
+type SeriesLine struct {
+	Source       string            `json:"source"`
+	Episodes     []string          `json:"episodes"`
+	Translations map[string]string `json:"translations"`
+}
+
+// SeriesMemory is shared by the episodes of a series so recurring lines,
+// like catchphrases, and character names are translated the same way in
+// every episode. Lines are established by the first episode translating
+// them; later episodes are prompted with them and checked against them.
+type SeriesMemory struct {
+	FileName string `json:"-"`
+	// MinWords is the number of words from which a line is kept consistent,
+	// shorter lines like "Okay." depend too much on the context
+	MinWords int          `json:"-"`
+	Names    []string     `json:"names"`
+	Lines    []SeriesLine `json:"lines"`
+	index    map[string]int
+}
+
+// LoadSeriesMemory reads the series memory file; a missing file is an empty memory
+func LoadSeriesMemory(fileName string) (*SeriesMemory, error) {
+	toReturn := &SeriesMemory{FileName: fileName}
+	contents, err := os.ReadFile(fileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return toReturn, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(contents, toReturn)
+	if err != nil {
+		return nil, fmt.Errorf("parsing series memory %s: %w", fileName, err)
+	}
+	return toReturn, nil
+}
+
+// Save writes the memory to its file
+func (s *SeriesMemory) Save() error {
+	contents, err := json.MarshalIndent(s, "", "    ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.FileName, contents, 0644)
+}
+
+func (s *SeriesMemory) line(source string) (*SeriesLine, bool) {
+	if s.index == nil {
+		s.index = map[string]int{}
+		for i, line := range s.Lines {
+			s.index[util.NormalizeText(line.Source)] = i
+		}
+	}
+	i, ok := s.index[util.NormalizeText(source)]
+	if !ok {
+		return nil, false
+	}
+	return &s.Lines[i], true
+}
+
+// Terms returns the character names and the lines established by other
+// episodes than episode for the target language, as glossary terms
+func (s *SeriesMemory) Terms(episode string, target string) []GlossaryTerm {
+	var toReturn []GlossaryTerm
+	for _, name := range s.Names {
+		toReturn = append(toReturn, GlossaryTerm{Source: name, Target: name, DoNotTranslate: true})
+	}
+	for _, line := range s.Lines {
+		translation, ok := line.Translations[target]
+		if !ok || len(strings.Fields(line.Source)) < s.MinWords {
+			continue
+		}
+		if len(line.Episodes) == 1 && line.Episodes[0] == episode {
+			continue
+		}
+		toReturn = append(toReturn, GlossaryTerm{Source: line.Source, Target: translation})
+	}
+	return toReturn
+}
+
+// Record adds the lines of an episode and their translations to the
+// memory. Lines keep the translation they were established with.
+func (s *SeriesMemory) Record(episode string, target string, source *astisub.Subtitles, translated *astisub.Subtitles, names ...string) {
+	for _, name := range names {
+		if name != "" && !slices.Contains(s.Names, name) {
+			s.Names = append(s.Names, name)
+		}
+	}
+	for i, item := range source.Items {
+		if i >= len(translated.Items) {
+			break
+		}
+		text := strings.TrimSpace(cueText(item))
+		if util.NormalizeText(text) == "" {
+			continue
+		}
+		line, ok := s.line(text)
+		if !ok {
+			s.index[util.NormalizeText(text)] = len(s.Lines)
+			s.Lines = append(s.Lines, SeriesLine{Source: text, Translations: map[string]string{}})
+			line = &s.Lines[len(s.Lines)-1]
+		}
+		if !slices.Contains(line.Episodes, episode) {
+			line.Episodes = append(line.Episodes, episode)
+		}
+		if _, ok := line.Translations[target]; !ok {
+			line.Translations[target] = strings.TrimSpace(cueText(translated.Items[i]))
+		}
+	}
+}
+
+// Episode names the episode being translated after its file
+func (tr *TranslationRequestBase) Episode() string {
+	return strings.TrimSuffix(filepath.Base(tr.SubtitleFileName), filepath.Ext(tr.SubtitleFileName))
+}
+
+// seriesTerms returns the series terms for the episode being translated.
+// They are built once per request so their patterns are only compiled once.
+func (tr *TranslationRequestBase) seriesTerms() []GlossaryTerm {
+	if tr.Series == nil {
+		return nil
+	}
+	if tr.established == nil {
+		tr.established = tr.Series.Terms(tr.Episode(), tr.TargetLanguage.String())
+	}
+	return tr.established
+}
+
+// SeriesTermsFor returns the series terms used in the given texts
+func (tr *TranslationRequestBase) SeriesTermsFor(texts ...string) []GlossaryTerm {
+	var toReturn []GlossaryTerm
+	terms := tr.seriesTerms()
+	for i := range terms {
+		for _, text := range texts {
+			if terms[i].Matches(text) {
+				toReturn = append(toReturn, terms[i])
+				break
+			}
+		}
+	}
+	return toReturn
+}
+
+// seriesContext identifies the series terms used in the given texts, so
+// recording another episode only invalidates the cues using a changed term
+func (tr *TranslationRequestBase) seriesContext(texts ...string) string {
+	var toReturn []string
+	for _, term := range tr.SeriesTermsFor(texts...) {
+		toReturn = append(toReturn, term.Source+"=>"+term.Target)
+	}
+	return TemplateVersion(strings.Join(toReturn, "\n"))
+}
+
+// VerifySeries checks every translated cue containing a recurring line or
+// a character name of the series for its established translation
+func (tr *TranslationRequestBase) VerifySeries(translated *astisub.Subtitles) []GlossaryViolation {
+	return tr.verifyTerms(tr.seriesTerms(), translated)
+}
+
+// WriteSeriesReport writes a table of the cues deviating from earlier episodes next to the source file
+func (tr *TranslationRequestBase) WriteSeriesReport(violations []GlossaryViolation) error {
+	return tr.writeViolations("series", violations)
+}
+
+// RecordSeries adds the translated episode to the series memory and saves it
+func (tr *TranslationRequestBase) RecordSeries(translated *astisub.Subtitles) error {
+	var names []string
+	if tr.Bible != nil {
+		for _, character := range tr.Bible.Characters {
+			names = append(names, character.Name)
+		}
+	}
+	tr.Series.Record(tr.Episode(), tr.TargetLanguage.String(), tr.Subtitles, translated, names...)
+	tr.established = nil
+	return tr.Series.Save()
+}
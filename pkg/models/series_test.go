@@ -0,0 +1,78 @@
+package models
+
+import (
+	"path"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stovak/gpt-subtitles/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/language"
+)
+
+func TestSeriesMemory(t *testing.T) {
+	series, err := LoadSeriesMemory(path.Join(t.TempDir(), "series.json"))
+	assert.NoError(t, err, "a missing memory is empty")
+	series.MinWords = 3
+	episode := func(name string) *TranslationRequestBase {
+		tr := &TranslationRequestBase{
+			SubtitleFileName: path.Join(util.GetRoot(), "test-fixtures", "TestFixture1.ttml"),
+			Cmd:              &cobra.Command{},
+			SourceLanguage:   language.English,
+			TargetLanguage:   language.Spanish,
+			Series:           series,
+		}
+		assert.NoError(t, tr.Parse(), "Parse()")
+		tr.SubtitleFileName = path.Join(path.Dir(series.FileName), name+".ttml")
+		return tr
+	}
+
+	context := episode("show-e07").cacheContext()
+	first := episode("show-e01")
+	assert.Equal(t, "show-e01", first.Episode())
+	assert.Empty(t, first.seriesTerms(), "nothing is established before the first episode")
+	first.Bible = &ShowBible{Characters: []Character{{Name: "Detective Ramos"}}}
+	translated := translatedCopy(first, func(s string) string { return "ES " + s })
+	assert.NoError(t, first.RecordSeries(translated), "RecordSeries()")
+	assert.Empty(t, first.seriesTerms()[1:], "lines of an episode are not established for the episode itself")
+
+	series, err = LoadSeriesMemory(series.FileName)
+	assert.NoError(t, err, "LoadSeriesMemory()")
+	series.MinWords = 3
+	seventh := episode("show-e07")
+	terms := seventh.SeriesTermsFor("Where's my lawyer?", "Yes.", "Ask Detective Ramos.")
+	assert.Len(t, terms, 2, "short lines are not kept consistent")
+	assert.Equal(t, "Detective Ramos", terms[0].Target)
+	assert.True(t, terms[0].DoNotTranslate, "names are not translated")
+	assert.Equal(t, "Where's my lawyer?", terms[1].Source)
+	assert.Equal(t, "ES Where's my lawyer?", terms[1].Target)
+	assert.Same(t, &seventh.seriesTerms()[0], &seventh.seriesTerms()[0], "the terms are built once per request")
+	assert.Equal(t, context, seventh.cacheContext(), "recording an episode does not invalidate the whole cache")
+	assert.Equal(t, first.seriesContext("Legally."), seventh.seriesContext("Legally."), "cues without series terms keep their keys")
+	assert.NotEqual(t, first.seriesContext("Where's my lawyer?"), seventh.seriesContext("Where's my lawyer?"))
+
+	translated = translatedCopy(seventh, func(s string) string { return "ES " + s })
+	translated.Items[9].Lines[0].Items[0].Text = "¿Y mi abogado?"
+	violations := seventh.VerifySeries(translated)
+	assert.Len(t, violations, 1)
+	assert.Equal(t, 9, violations[0].Index)
+	assert.NoError(t, seventh.RecordSeries(translated), "RecordSeries()")
+	line, _ := series.line("where's my lawyer")
+	assert.Equal(t, []string{"show-e01", "show-e07"}, line.Episodes)
+	assert.Equal(t, "ES Where's my lawyer?", line.Translations["es"], "the first translation stays established")
+}
+
+func TestGPTTranslateRequest_toPrompt_series(t *testing.T) {
+	tr, err := NewGPTTranslationRequestFromFile(
+		path.Join(util.GetRoot(), "test-fixtures", "TestFixture1.ttml"),
+		"en", "es", &cobra.Command{})
+	assert.NoError(t, err, "NewGPTTranslationRequestFromFile()")
+	gpt := tr.(*GPTTranslationRequest)
+	gpt.Series = &SeriesMemory{MinWords: 3, Lines: []SeriesLine{
+		{Source: "Where's my lawyer?", Episodes: []string{"show-e01"}, Translations: map[string]string{"es": "¿Dónde está mi abogado?"}},
+	}}
+	prompt, err := gpt.toPrompt([]int{9}, []string{"Where's my lawyer?"})
+	assert.NoError(t, err, "toPrompt()")
+	assert.Contains(t, prompt, "recur across the episodes of the series")
+	assert.Contains(t, prompt, "Where's my lawyer? => ¿Dónde está mi abogado?")
+}
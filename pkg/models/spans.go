@@ -0,0 +1,101 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// dntMarkup marks a span in the source that must pass through untouched, e.g. "visit [[celebrity-news.flash]]"
+	dntMarkup = regexp.MustCompile(`\[\[(.+?)\]\]`)
+	// placeholderPattern matches the opaque placeholders sent to engines in place of protected spans
+	placeholderPattern = regexp.MustCompile(`⟦\d+⟧`)
+)
+
+// SpanProtector swaps do-not-translate spans for opaque placeholders before
+// the text is sent to an engine and puts them back afterwards. Spans are
+// either marked inline in the source with [[...]] or matched by a rule.
+type SpanProtector struct {
+	Rules        []*regexp.Regexp
+	placeholders map[string]string
+	originals    map[string]string
+}
+
+// NewSpanProtector compiles the regular expressions of the do-not-translate rules
+func NewSpanProtector(rules []string) (*SpanProtector, error) {
+	toReturn := &SpanProtector{}
+	for _, rule := range rules {
+		re, err := regexp.Compile(rule)
+		if err != nil {
+			return nil, fmt.Errorf("invalid do-not-translate rule %q: %w", rule, err)
+		}
+		toReturn.Rules = append(toReturn.Rules, re)
+	}
+	return toReturn, nil
+}
+
+// Protect replaces the protected spans of text with placeholders. The same
+// span always gets the same placeholder, so protecting is repeatable.
+func (p *SpanProtector) Protect(text string) string {
+	text = dntMarkup.ReplaceAllStringFunc(text, func(match string) string {
+		return p.placeholderFor(dntMarkup.FindStringSubmatch(match)[1])
+	})
+	for _, rule := range p.Rules {
+		text = replaceOutsidePlaceholders(text, rule, p.placeholderFor)
+	}
+	return text
+}
+
+// Restore puts the original spans back into a translation of the protected
+// source. It fails when the engine dropped, duplicated or mangled a placeholder.
+func (p *SpanProtector) Restore(protected string, translated string) (string, error) {
+	want := placeholderPattern.FindAllString(protected, -1)
+	got := placeholderPattern.FindAllString(translated, -1)
+	counts := map[string]int{}
+	for _, placeholder := range got {
+		counts[placeholder]++
+	}
+	for _, placeholder := range want {
+		if counts[placeholder] == 0 {
+			return "", fmt.Errorf("placeholder %s for %q is missing from translation %q", placeholder, p.originals[placeholder], translated)
+		}
+		counts[placeholder]--
+	}
+	for placeholder, count := range counts {
+		if count > 0 {
+			return "", fmt.Errorf("unexpected placeholder %s in translation %q", placeholder, translated)
+		}
+	}
+	return placeholderPattern.ReplaceAllStringFunc(translated, func(placeholder string) string {
+		return p.originals[placeholder]
+	}), nil
+}
+
+func (p *SpanProtector) placeholderFor(span string) string {
+	if p.placeholders == nil {
+		p.placeholders = map[string]string{}
+		p.originals = map[string]string{}
+	}
+	if placeholder, ok := p.placeholders[span]; ok {
+		return placeholder
+	}
+	placeholder := fmt.Sprintf("⟦%d⟧", len(p.placeholders)+1)
+	p.placeholders[span] = placeholder
+	p.originals[placeholder] = span
+	return placeholder
+}
+
+// replaceOutsidePlaceholders applies rule only to the parts of text that are
+// not already placeholders, so rules cannot match inside each other's output
+func replaceOutsidePlaceholders(text string, rule *regexp.Regexp, replace func(string) string) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range placeholderPattern.FindAllStringIndex(text, -1) {
+		b.WriteString(rule.ReplaceAllStringFunc(text[last:loc[0]], replace))
+		b.WriteString(text[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	b.WriteString(rule.ReplaceAllStringFunc(text[last:], replace))
+	return b.String()
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpanProtector_Protect(t *testing.T) {
+	p, err := NewSpanProtector([]string{`[\w-]+\.flash`, `#\w+`})
+	assert.NoError(t, err, "NewSpanProtector()")
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{name: "Protect-none", text: "Yes.", want: "Yes."},
+		{name: "Protect-rule", text: "celebrity-news.flash", want: "⟦1⟧"},
+		{name: "Protect-markup", text: "Ask [[Walter Cronkite]] about #breaking", want: "Ask ⟦2⟧ about ⟦3⟧"},
+		{name: "Protect-repeat", text: "celebrity-news.flash #breaking", want: "⟦1⟧ ⟦3⟧"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equalf(t, tt.want, p.Protect(tt.text), "Protect(%s)", tt.text)
+		})
+	}
+}
+
+func TestSpanProtector_Restore(t *testing.T) {
+	p, err := NewSpanProtector([]string{`[\w-]+\.flash`})
+	assert.NoError(t, err, "NewSpanProtector()")
+	protected := p.Protect("Do you work for celebrity-news.flash, [[Dan]]?")
+	tests := []struct {
+		name       string
+		translated string
+		want       string
+		wantErr    assert.ErrorAssertionFunc
+	}{
+		{name: "Restore-ok", translated: "¿Trabajas para ⟦2⟧, ⟦1⟧?", want: "¿Trabajas para celebrity-news.flash, Dan?", wantErr: assert.NoError},
+		{name: "Restore-dropped", translated: "¿Trabajas para ⟦2⟧?", wantErr: assert.Error},
+		{name: "Restore-duplicated", translated: "⟦1⟧ ⟦1⟧ ⟦2⟧", wantErr: assert.Error},
+		{name: "Restore-mangled", translated: "¿Trabajas para ⟦2⟧, ⟦١⟧?", wantErr: assert.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := p.Restore(protected, tt.translated)
+			tt.wantErr(t, err, "Restore(%s)", tt.translated)
+			assert.Equalf(t, tt.want, got, "Restore(%s)", tt.translated)
+		})
+	}
+}
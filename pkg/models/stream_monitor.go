@@ -0,0 +1,103 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/language"
+)
+
+var (
+	// refusalPattern matches the usual openings of an LLM refusing or explaining instead of translating
+	refusalPattern = regexp.MustCompile(`(?i)^\W*(i'm sorry|i am sorry|i apologi[sz]e|i can't|i cannot|i'm unable|i am unable|as an ai)`)
+	// targetScripts are the scripts the letters of a translation are expected to be written in
+	targetScripts = map[string][]*unicode.RangeTable{
+		"ar": {unicode.Arabic},
+		"hi": {unicode.Devanagari},
+		"ru": {unicode.Cyrillic},
+		"zh": {unicode.Han},
+		"ja": {unicode.Han, unicode.Hiragana, unicode.Katakana},
+		"ko": {unicode.Hangul, unicode.Han},
+		"en": {unicode.Latin},
+		"es": {unicode.Latin},
+		"de": {unicode.Latin},
+		"fr": {unicode.Latin},
+		"pt": {unicode.Latin},
+	}
+)
+
+// streamMonitor watches a streamed batch translation and reports when it has
+// clearly gone off the rails, so the request can be aborted early
+type streamMonitor struct {
+	target language.Tag
+	source []string
+	// minCues is the number of completed cues needed before judging the language
+	minCues int
+}
+
+func newStreamMonitor(target language.Tag, source []string) *streamMonitor {
+	return &streamMonitor{
+		target:  target,
+		source:  source,
+		minCues: 3,
+	}
+}
+
+// Check inspects the completed cues and the raw content received so far, in
+// which the cues are separated by |
+func (m *streamMonitor) Check(cues []string, content string) error {
+	base, _ := m.target.Base()
+	if base.String() != "en" && len(strings.TrimSpace(content)) >= 12 && refusalPattern.MatchString(content) {
+		if len(m.source) == 0 || !refusalPattern.MatchString(m.source[0]) {
+			return fmt.Errorf("response looks like a refusal: %q", truncate(content, 80))
+		}
+	}
+	// the cue being received counts as soon as it has any text
+	received := len(cues)
+	if strings.TrimSpace(content[strings.LastIndex(content, "|")+1:]) != "" {
+		received++
+	}
+	if received > len(m.source) {
+		return fmt.Errorf("response has more cues (%d) than the batch (%d)", received, len(m.source))
+	}
+	if len(cues) < m.minCues {
+		return nil
+	}
+	if scripts, ok := targetScripts[base.String()]; ok {
+		letters, matching := 0, 0
+		for _, cue := range cues {
+			for _, r := range cue {
+				if !unicode.IsLetter(r) {
+					continue
+				}
+				letters++
+				if unicode.In(r, scripts...) {
+					matching++
+				}
+			}
+		}
+		if letters > 0 && matching*2 < letters {
+			return fmt.Errorf("response does not look like %s: %q", m.target, truncate(strings.Join(cues, "|"), 80))
+		}
+	}
+	untranslated := 0
+	for i, cue := range cues {
+		if strings.EqualFold(strings.TrimSpace(cue), strings.TrimSpace(m.source[i])) {
+			untranslated++
+		}
+	}
+	if len(cues) >= 5 && untranslated*5 >= len(cues)*4 {
+		return fmt.Errorf("response repeats the source text instead of translating it")
+	}
+	return nil
+}
+
+func truncate(s string, length int) string {
+	runes := []rune(s)
+	if len(runes) <= length {
+		return s
+	}
+	return string(runes[:length]) + "…"
+}
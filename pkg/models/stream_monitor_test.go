@@ -0,0 +1,36 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/language"
+)
+
+func TestStreamMonitor_Check(t *testing.T) {
+	source := []string{"Yes.", "Where's my lawyer?", "On the way.", "Legally.", "I want my camera back."}
+	tests := []struct {
+		name    string
+		target  string
+		content string
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{name: "Check-ok", target: "es", content: "Sí.|¿Dónde está mi abogado?|En camino.|Leg", wantErr: assert.NoError},
+		{name: "Check-refusal", target: "es", content: "I'm sorry, but I can't help with that.", wantErr: assert.Error},
+		{name: "Check-refusal-english-target", target: "en", content: "I'm sorry, but I can't help with that.", wantErr: assert.NoError},
+		{name: "Check-wrong-script", target: "ja", content: "Sí.|¿Dónde está mi abogado?|En camino.|", wantErr: assert.Error},
+		{name: "Check-right-script", target: "ja", content: "はい。|弁護士はどこ？|向かっています。|", wantErr: assert.NoError},
+		{name: "Check-untranslated", target: "es", content: strings.Join(source, "|") + "|", wantErr: assert.Error},
+		{name: "Check-too-many-cues", target: "es", content: "a|b|c|d|e|f|g", wantErr: assert.Error},
+		{name: "Check-one-extra-cue", target: "es", content: "Sí.|¿Dónde está mi abogado?|En camino.|Legalmente.|Quiero mi cámara.|Y", wantErr: assert.Error},
+		{name: "Check-trailing-separator", target: "es", content: "Sí.|¿Dónde está mi abogado?|En camino.|Legalmente.|Quiero mi cámara.|", wantErr: assert.NoError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newStreamMonitor(language.MustParse(tt.target), source)
+			cues := strings.Split(tt.content, "|")
+			tt.wantErr(t, m.Check(cues[:len(cues)-1], tt.content), "Check(%s)", tt.content)
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/asticode/go-astisub"
+)
+
+// styleTagPattern matches the neutral tags like <s1>…</s1> that stand for the
+// styled spans of a cue (italics, bold, colour...) while it is with an engine
+var styleTagPattern = regexp.MustCompile(`</?s(\d+)>`)
+
+// adjacentTags matches a closing tag followed by the opening tag of the same
+// style, as left by a style spanning several line items or lines
+var adjacentTags = regexp.MustCompile(`</s(\d+)>(\s*)<s(\d+)>`)
+
+// styleSignature identifies the style of a line item, "" when it is not styled
+func styleSignature(item astisub.LineItem) string {
+	var inline astisub.StyleAttributes
+	if item.InlineStyle != nil {
+		inline = *item.InlineStyle
+	}
+	if len(inline.WebVTTTags) == 0 {
+		inline.WebVTTTags = nil
+	}
+	var style string
+	if item.Style != nil {
+		style = item.Style.ID
+	}
+	if style == "" && reflect.DeepEqual(inline, astisub.StyleAttributes{}) {
+		return ""
+	}
+	contents, _ := json.Marshal(struct {
+		Style  string
+		Inline astisub.StyleAttributes
+	}{style, inline})
+	return string(contents)
+}
+
+// taggedText returns the text of a cue like cueText, with its styled spans
+// wrapped in neutral tags. Tag n stands for the n-th of styles, the styles
+// of the cue not in styles yet are added to it.
+func taggedText(item *astisub.Item, styles []astisub.LineItem) (string, []astisub.LineItem) {
+	var lines []string
+	for _, line := range item.Lines {
+		var b strings.Builder
+		for _, lineItem := range line.Items {
+			signature := styleSignature(lineItem)
+			if signature == "" || strings.TrimSpace(lineItem.Text) == "" {
+				b.WriteString(lineItem.Text)
+				continue
+			}
+			n := 0
+			for i, style := range styles {
+				if styleSignature(style) == signature {
+					n = i + 1
+				}
+			}
+			if n == 0 {
+				styles = append(styles, astisub.LineItem{InlineStyle: lineItem.InlineStyle, Style: lineItem.Style})
+				n = len(styles)
+			}
+			// spaces around the text stay outside the tags
+			text := strings.TrimSpace(lineItem.Text)
+			lead := lineItem.Text[:strings.Index(lineItem.Text, text)]
+			trail := lineItem.Text[len(lead)+len(text):]
+			b.WriteString(lead + "<s" + strconv.Itoa(n) + ">" + text + "</s" + strconv.Itoa(n) + ">" + trail)
+		}
+		if text := strings.TrimSpace(b.String()); text != "" {
+			lines = append(lines, text)
+		}
+	}
+	text := adjacentTags.ReplaceAllStringFunc(strings.Join(lines, " "), func(tags string) string {
+		match := adjacentTags.FindStringSubmatch(tags)
+		if match[1] == match[3] {
+			return match[2]
+		}
+		return tags
+	})
+	return text, styles
+}
+
+// styledRun is a part of a word in a single style, 0 when it is not styled
+type styledRun struct {
+	Text  string
+	Style int
+}
+
+// styledWord is a word of a translation, made of one run per style
+type styledWord []styledRun
+
+func (w styledWord) String() string {
+	var b strings.Builder
+	for _, run := range w {
+		b.WriteString(run.Text)
+	}
+	return b.String()
+}
+
+// styledWords splits the translation of the source cue into words and reads
+// its styling tags. A translation without tags of a cue that is styled as a
+// whole gets the style of the cue; the loss of any other tag is reported.
+func (tr *TranslationRequestBase) styledWords(source *astisub.Item, text string) ([]styledWord, []astisub.LineItem) {
+	tagged, styles := taggedText(source, nil)
+	used := map[int]bool{}
+	for _, match := range styleTagPattern.FindAllStringSubmatch(text, -1) {
+		n, _ := strconv.Atoi(match[1])
+		used[n] = true
+	}
+	if len(styles) == 1 && len(used) == 0 && strings.HasPrefix(tagged, "<s1>") && strings.Index(tagged, "</s1>") == len(tagged)-len("</s1>") {
+		text = "<s1>" + text + "</s1>"
+		used[1] = true
+	}
+	for n := range styles {
+		if !used[n+1] {
+			tr.Cmd.PrintErrf("Cue at %s lost the styling tags in translation, its styled text is plain: %s\n", FormatTimecode(source.StartAt), text)
+			break
+		}
+	}
+	var words []styledWord
+	var word styledWord
+	style := 0
+	appendRune := func(r rune) {
+		if len(word) == 0 || word[len(word)-1].Style != style {
+			word = append(word, styledRun{Style: style})
+		}
+		word[len(word)-1].Text += string(r)
+	}
+	last := 0
+	for _, tag := range append(styleTagPattern.FindAllStringSubmatchIndex(text, -1), []int{len(text), len(text), len(text), len(text)}) {
+		for _, r := range text[last:tag[0]] {
+			if unicode.IsSpace(r) {
+				if len(word) > 0 {
+					words = append(words, word)
+				}
+				word = nil
+				continue
+			}
+			appendRune(r)
+		}
+		last = tag[1]
+		if tag[0] == len(text) {
+			break
+		}
+		n, _ := strconv.Atoi(text[tag[2]:tag[3]])
+		switch {
+		case text[tag[0]+1] == '/' || n > len(styles):
+			style = 0
+		default:
+			style = n
+		}
+	}
+	if len(word) > 0 {
+		words = append(words, word)
+	}
+	return words, styles
+}
+
+// lineItems returns the line items of a line of words joined by separator,
+// one per styled span. The space between two words is styled when both sides
+// are.
+func lineItems(words []styledWord, styles []astisub.LineItem, separator string) []astisub.LineItem {
+	var runs []styledRun
+	add := func(run styledRun) {
+		if len(runs) > 0 && runs[len(runs)-1].Style == run.Style {
+			runs[len(runs)-1].Text += run.Text
+			return
+		}
+		runs = append(runs, run)
+	}
+	for i, word := range words {
+		if i > 0 && separator != "" {
+			space := styledRun{Text: separator}
+			if previous := words[i-1][len(words[i-1])-1].Style; previous == word[0].Style {
+				space.Style = previous
+			}
+			add(space)
+		}
+		for _, run := range word {
+			add(run)
+		}
+	}
+	toReturn := make([]astisub.LineItem, len(runs))
+	for i, run := range runs {
+		toReturn[i] = astisub.LineItem{Text: run.Text}
+		if run.Style > 0 {
+			toReturn[i].InlineStyle = styles[run.Style-1].InlineStyle
+			toReturn[i].Style = styles[run.Style-1].Style
+		}
+	}
+	if len(toReturn) == 0 {
+		toReturn = []astisub.LineItem{{}}
+	}
+	return toReturn
+}
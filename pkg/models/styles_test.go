@@ -0,0 +1,112 @@
+package models
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/asticode/go-astisub"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaggedText(t *testing.T) {
+	italic := &astisub.StyleAttributes{TTMLFontStyle: &[]string{"italic"}[0]}
+	bold := &astisub.StyleAttributes{TTMLFontWeight: &[]string{"bold"}[0]}
+	item := &astisub.Item{Lines: []astisub.Line{
+		{Items: []astisub.LineItem{{Text: "Where's my ", InlineStyle: &astisub.StyleAttributes{}}, {Text: "lawyer?", InlineStyle: italic}}},
+		{Items: []astisub.LineItem{{Text: "On the way.", InlineStyle: italic}, {Text: " Really", InlineStyle: bold}}},
+	}}
+	text, styles := taggedText(item, nil)
+	assert.Equal(t, "Where's my <s1>lawyer? On the way.</s1> <s2>Really</s2>", text, "a style spanning lines is a single tag")
+	assert.Len(t, styles, 2)
+	assert.Equal(t, "Where's my lawyer? On the way. Really", cueText(item))
+
+	cmd := &cobra.Command{}
+	errs := new(bytes.Buffer)
+	cmd.SetErr(errs)
+	tr := &TranslationRequestBase{Cmd: cmd}
+	lines := tr.cueLines(item, "¿Dónde está mi <s1>abogado? En camino.</s1> <s2>De verdad</s2>")
+	assert.Len(t, lines, 2)
+	assert.Equal(t, []astisub.LineItem{{Text: "¿Dónde está mi "}, {Text: "abogado?", InlineStyle: italic}}, lines[0].Items)
+	assert.Equal(t, []astisub.LineItem{{Text: "En camino.", InlineStyle: italic}, {Text: " "}, {Text: "De verdad", InlineStyle: bold}}, lines[1].Items)
+	assert.Empty(t, errs.String(), "no tags were lost")
+
+	lines = tr.cueLines(item, "¿Dónde está mi abogado? En camino. <s2>De verdad</s2>")
+	assert.Contains(t, errs.String(), "lost the styling tags")
+	assert.Equal(t, "¿Dónde está mi abogado? En camino. De verdad", cueText(&astisub.Item{Lines: lines}))
+
+	whole := &astisub.Item{Lines: []astisub.Line{{Items: []astisub.LineItem{{Text: "Where's my lawyer?", InlineStyle: italic}}}}}
+	errs.Reset()
+	lines = tr.cueLines(whole, "¿Y mi abogado?")
+	assert.Equal(t, []astisub.LineItem{{Text: "¿Y mi abogado?", InlineStyle: italic}}, lines[0].Items, "a cue styled as a whole keeps its style")
+	assert.Empty(t, errs.String())
+}
+
+func TestGPTTranslateRequest_GetTranslated_styles(t *testing.T) {
+	var prompts []string
+	newFakeOpenAI(t, func(prompt string) string {
+		prompts = append(prompts, prompt)
+		return fakeTranslation(prompt)
+	})
+	fileName := path.Join(t.TempDir(), "styled.ttml")
+	err := os.WriteFile(fileName, []byte(`<?xml version="1.0" encoding="utf-8"?>
+<tt xmlns="http://www.w3.org/ns/ttml" xmlns:tts="http://www.w3.org/ns/ttml#styling" xml:lang="en">
+  <body>
+    <div>
+      <p begin="1s" end="2s">Where's my <span tts:fontStyle="italic">lawyer</span>?</p>
+      <p begin="3s" end="4s">On the way.</p>
+    </div>
+  </body>
+</tt>`), 0644)
+	assert.NoError(t, err, "WriteFile()")
+	tr, err := NewGPTTranslationRequestFromFile(fileName, "en", "es", &cobra.Command{})
+	assert.NoError(t, err, "NewGPTTranslationRequestFromFile()")
+	assert.Equal(t, []string{"Where's my <s1>lawyer</s1>?", "On the way."}, tr.GetSourceText())
+
+	err = tr.Translate()
+	assert.NoError(t, err, "Translate()")
+	assert.Contains(t, prompts[0], "Tags like <s1> and </s1> mark italics")
+	translated, err := tr.GetTranslated()
+	assert.NoError(t, err, "GetTranslated()")
+	items := translated.Items[0].Lines[0].Items
+	assert.Len(t, items, 3)
+	assert.Equal(t, "lawyer", items[1].Text)
+	assert.Equal(t, "italic", *items[1].InlineStyle.TTMLFontStyle)
+	out := new(strings.Builder)
+	assert.NoError(t, translated.WriteToTTML(out), "WriteToTTML()")
+	assert.Contains(t, out.String(), `tts:fontStyle="italic"`)
+}
+
+func TestReviewer_Review_styles(t *testing.T) {
+	var prompts []string
+	newFakeOpenAI(t, func(prompt string) string {
+		prompts = append(prompts, prompt)
+		return `[{"cue": 1, "translation": "¿Dónde está mi <s1>abogado</s1>?", "reason": "wording"}]`
+	})
+	fileName := path.Join(t.TempDir(), "styled.ttml")
+	err := os.WriteFile(fileName, []byte(`<?xml version="1.0" encoding="utf-8"?>
+<tt xmlns="http://www.w3.org/ns/ttml" xmlns:tts="http://www.w3.org/ns/ttml#styling" xml:lang="en">
+  <body>
+    <div>
+      <p begin="1s" end="2s">Where's my <span tts:fontStyle="italic">lawyer</span>?</p>
+    </div>
+  </body>
+</tt>`), 0644)
+	assert.NoError(t, err, "WriteFile()")
+	tr := &TranslationRequestBase{SubtitleFileName: fileName, Cmd: &cobra.Command{}}
+	assert.NoError(t, tr.Parse(), "Parse()")
+	translated := &astisub.Subtitles{Items: []*astisub.Item{tr.translatedItem(tr.Subtitles.Items[0], "¿Y mi <s1>abogado</s1>?")}}
+
+	edits, err := NewReviewer("gpt-review-request.tmpl").Review(tr, translated)
+	assert.NoError(t, err, "Review()")
+	assert.Len(t, edits, 1)
+	assert.Contains(t, prompts[0], "Keep every tag of a translation you change")
+	assert.Contains(t, prompts[0], "1. Where's my <s1>lawyer</s1>?\n=> ¿Y mi <s1>abogado</s1>?")
+	items := translated.Items[0].Lines[0].Items
+	assert.Len(t, items, 3)
+	assert.Equal(t, "abogado", items[1].Text)
+	assert.Equal(t, "italic", *items[1].InlineStyle.TTMLFontStyle, "the correction keeps the italics")
+}
@@ -0,0 +1,444 @@
+package models
+
+import (
+	"cmp"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/stovak/gpt-subtitles/pkg/util"
+	"golang.org/x/text/language"
+)
+
+// MemoryUnit is an approved translation of a source segment
+type MemoryUnit struct {
+	SourceLanguage string    `json:"source_language"`
+	TargetLanguage string    `json:"target_language"`
+	Source         string    `json:"source"`
+	Target         string    `json:"target"`
+	Origin         string    `json:"origin"`
+	Created        time.Time `json:"created"`
+}
+
+// TranslationMemory is a local store of approved translations, imported
+// from TMX files of other CAT tools or exported from our own translations.
+// Engines use exact matches from it instead of calling the network.
+type TranslationMemory struct {
+	FileName   string
+	Units      []MemoryUnit
+	Options    MemoryOptions
+	index      map[string]int
+	normalized []string
+}
+
+// MemoryOptions configure the use of fuzzy matches, with similarities from 0 to 1
+type MemoryOptions struct {
+	// Fuzzy is the similarity from which matches are passed to LLM prompts as references
+	Fuzzy float64 `mapstructure:"fuzzy"`
+	// References is the maximum number of references per cue
+	References int `mapstructure:"references"`
+	// Accept is the similarity from which a match is used as translation without the engine; zero disables it
+	Accept float64 `mapstructure:"accept"`
+}
+
+// FuzzyMatch is a memory unit similar to a cue
+type FuzzyMatch struct {
+	MemoryUnit
+	Score float64
+}
+
+// MemoryHit is a cue translated from the memory
+type MemoryHit struct {
+	Index  int
+	Score  float64
+	Source string
+}
+
+// LoadTranslationMemory reads the translation memory file; a missing file is an empty memory
+func LoadTranslationMemory(fileName string) (*TranslationMemory, error) {
+	toReturn := &TranslationMemory{FileName: fileName}
+	contents, err := os.ReadFile(fileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return toReturn, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(contents, &toReturn.Units)
+	if err != nil {
+		return nil, fmt.Errorf("parsing translation memory %s: %w", fileName, err)
+	}
+	return toReturn, nil
+}
+
+// memoryKey identifies a segment by the base languages and its text with normalized whitespace
+func memoryKey(source string, target string, text string) string {
+	return strings.Join([]string{baseLanguage(source), baseLanguage(target), strings.Join(strings.Fields(text), " ")}, "|")
+}
+
+func baseLanguage(code string) string {
+	tag, err := language.Parse(strings.TrimSpace(code))
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(code))
+	}
+	base, _ := tag.Base()
+	return base.String()
+}
+
+func (m *TranslationMemory) buildIndex() {
+	m.index = map[string]int{}
+	for i, unit := range m.Units {
+		m.index[memoryKey(unit.SourceLanguage, unit.TargetLanguage, unit.Source)] = i
+	}
+}
+
+// Add stores the units, replacing the translation of segments already in
+// the memory, and returns how many were new
+func (m *TranslationMemory) Add(units ...MemoryUnit) int {
+	if m.index == nil {
+		m.buildIndex()
+	}
+	added := 0
+	for _, unit := range units {
+		key := memoryKey(unit.SourceLanguage, unit.TargetLanguage, unit.Source)
+		if i, ok := m.index[key]; ok {
+			m.Units[i] = unit
+			continue
+		}
+		m.index[key] = len(m.Units)
+		m.Units = append(m.Units, unit)
+		added++
+	}
+	m.normalized = nil
+	return added
+}
+
+// Lookup returns the approved translation of an exact match of text
+func (m *TranslationMemory) Lookup(source language.Tag, target language.Tag, text string) (string, bool) {
+	if m.index == nil {
+		m.buildIndex()
+	}
+	i, ok := m.index[memoryKey(source.String(), target.String(), text)]
+	if !ok {
+		return "", false
+	}
+	return m.Units[i].Target, true
+}
+
+// Fuzzy returns up to limit units of the language pair whose source is at
+// least threshold similar to text, best first
+func (m *TranslationMemory) Fuzzy(source language.Tag, target language.Tag, text string, threshold float64, limit int) []FuzzyMatch {
+	if m.normalized == nil {
+		m.normalized = make([]string, len(m.Units))
+		for i, unit := range m.Units {
+			m.normalized[i] = util.NormalizeText(unit.Source)
+		}
+	}
+	normalized := util.NormalizeText(text)
+	length := utf8.RuneCountInString(normalized)
+	sourceBase, targetBase := baseLanguage(source.String()), baseLanguage(target.String())
+	var toReturn []FuzzyMatch
+	for i, unit := range m.Units {
+		// the similarity of texts can be no higher than the ratio of their lengths allows
+		unitLength := utf8.RuneCountInString(m.normalized[i])
+		if float64(2*min(length, unitLength)) < threshold*float64(length+unitLength) {
+			continue
+		}
+		if baseLanguage(unit.SourceLanguage) != sourceBase || baseLanguage(unit.TargetLanguage) != targetBase {
+			continue
+		}
+		score := util.Similarity(normalized, m.normalized[i])
+		if score >= threshold {
+			toReturn = append(toReturn, FuzzyMatch{MemoryUnit: unit, Score: score})
+		}
+	}
+	slices.SortStableFunc(toReturn, func(a, b FuzzyMatch) int {
+		return cmp.Compare(b.Score, a.Score)
+	})
+	return toReturn[:min(limit, len(toReturn))]
+}
+
+// Save writes the memory to its file
+func (m *TranslationMemory) Save() error {
+	contents, err := json.MarshalIndent(m.Units, "", "    ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(m.FileName, contents, 0644)
+}
+
+type tmxDocument struct {
+	XMLName xml.Name  `xml:"tmx"`
+	Version string    `xml:"version,attr"`
+	Header  tmxHeader `xml:"header"`
+	Units   []tmxUnit `xml:"body>tu"`
+}
+
+type tmxHeader struct {
+	CreationTool        string `xml:"creationtool,attr"`
+	CreationToolVersion string `xml:"creationtoolversion,attr"`
+	SegType             string `xml:"segtype,attr"`
+	OTMF                string `xml:"o-tmf,attr"`
+	AdminLang           string `xml:"adminlang,attr"`
+	SrcLang             string `xml:"srclang,attr"`
+	DataType            string `xml:"datatype,attr"`
+}
+
+type tmxUnit struct {
+	SrcLang      string       `xml:"srclang,attr,omitempty"`
+	CreationDate string       `xml:"creationdate,attr,omitempty"`
+	Variants     []tmxVariant `xml:"tuv"`
+}
+
+type tmxVariant struct {
+	// Lang is xml:lang in TMX 1.4 and lang in older versions
+	Lang    string     `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+	OldLang string     `xml:"lang,attr,omitempty"`
+	Segment tmxSegment `xml:"seg"`
+}
+
+// tmxCodes are the inline elements of a segment holding native codes, such
+// as formatting tags or placeholders, rather than text
+var tmxCodes = map[string]bool{"bpt": true, "ept": true, "it": true, "ph": true, "ut": true}
+
+// tmxSegment is the text of a seg. The native codes are left out, while the
+// text of highlighted spans is kept.
+type tmxSegment string
+
+// UnmarshalXML collects the text of the seg and its highlighted spans
+func (s *tmxSegment) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var text strings.Builder
+	depth, code := 0, 0
+	for {
+		token, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+			if code == 0 && tmxCodes[t.Name.Local] {
+				code = depth
+			}
+		case xml.EndElement:
+			if depth == 0 {
+				*s = tmxSegment(text.String())
+				return nil
+			}
+			if depth == code {
+				code = 0
+			}
+			depth--
+		case xml.CharData:
+			if code == 0 {
+				text.Write(t)
+			}
+		}
+	}
+}
+
+// String returns the text of the segment with the whitespace left around the
+// removed codes collapsed
+func (s tmxSegment) String() string {
+	return strings.Join(strings.Fields(string(s)), " ")
+}
+
+func (v tmxVariant) language() string {
+	if v.Lang != "" {
+		return v.Lang
+	}
+	return v.OldLang
+}
+
+// tmxDate is the format of TMX dates, e.g. 20250301T120000Z
+const tmxDate = "20060102T150405Z"
+
+// ReadTMX reads the translation units of a TMX file. Every target variant
+// of a unit becomes a MemoryUnit from the source variant.
+func ReadTMX(r io.Reader, origin string) ([]MemoryUnit, error) {
+	var doc tmxDocument
+	err := xml.NewDecoder(r).Decode(&doc)
+	if err != nil {
+		return nil, fmt.Errorf("parsing TMX: %w", err)
+	}
+	var toReturn []MemoryUnit
+	for _, tu := range doc.Units {
+		srcLang := tu.SrcLang
+		if srcLang == "" || srcLang == "*all*" {
+			srcLang = doc.Header.SrcLang
+		}
+		var source *tmxVariant
+		for i := range tu.Variants {
+			if baseLanguage(tu.Variants[i].language()) == baseLanguage(srcLang) {
+				source = &tu.Variants[i]
+				break
+			}
+		}
+		if source == nil || source.Segment.String() == "" {
+			continue
+		}
+		created, _ := time.Parse(tmxDate, tu.CreationDate)
+		for _, variant := range tu.Variants {
+			if baseLanguage(variant.language()) == baseLanguage(srcLang) || variant.Segment.String() == "" {
+				continue
+			}
+			toReturn = append(toReturn, MemoryUnit{
+				SourceLanguage: source.language(),
+				TargetLanguage: variant.language(),
+				Source:         source.Segment.String(),
+				Target:         variant.Segment.String(),
+				Origin:         origin,
+				Created:        created,
+			})
+		}
+	}
+	return toReturn, nil
+}
+
+// WriteTMX writes the units as a TMX 1.4 document
+func WriteTMX(w io.Writer, units []MemoryUnit, srcLang string) error {
+	doc := tmxDocument{
+		Version: "1.4",
+		Header: tmxHeader{
+			CreationTool:        "gpt-subtitles",
+			CreationToolVersion: "1",
+			SegType:             "sentence",
+			OTMF:                "gpt-subtitles",
+			AdminLang:           "en",
+			SrcLang:             srcLang,
+			DataType:            "plaintext",
+		},
+	}
+	for _, unit := range units {
+		tu := tmxUnit{
+			Variants: []tmxVariant{
+				{Lang: unit.SourceLanguage, Segment: tmxSegment(unit.Source)},
+				{Lang: unit.TargetLanguage, Segment: tmxSegment(unit.Target)},
+			},
+		}
+		if !unit.Created.IsZero() {
+			tu.CreationDate = unit.Created.UTC().Format(tmxDate)
+		}
+		doc.Units = append(doc.Units, tu)
+	}
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	err = encoder.Encode(doc)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
+
+// TranslatedFileLanguage returns the language of a translation from its
+// file name, e.g. es for movie_es.ttml, the translation of movie.ttml
+func TranslatedFileLanguage(sourceFileName string, translatedFileName string) (language.Tag, error) {
+	base := strings.TrimSuffix(filepath.Base(sourceFileName), filepath.Ext(sourceFileName)) + "_"
+	name := strings.TrimSuffix(filepath.Base(translatedFileName), filepath.Ext(translatedFileName))
+	code, ok := strings.CutPrefix(name, base)
+	if !ok {
+		return language.Und, fmt.Errorf("%s is not named like a translation of %s", translatedFileName, sourceFileName)
+	}
+	return language.Parse(code)
+}
+
+// LoadMemoryFromTranslation pairs the cues of an approved translation with
+// the cues of its source file
+func LoadMemoryFromTranslation(sourceFileName string, targetFileName string, source language.Tag, target language.Tag, now time.Time) ([]MemoryUnit, error) {
+	examples, err := LoadExamplesFromTranslation(sourceFileName, targetFileName)
+	if err != nil {
+		return nil, err
+	}
+	var toReturn []MemoryUnit
+	for _, example := range examples {
+		toReturn = append(toReturn, MemoryUnit{
+			SourceLanguage: source.String(),
+			TargetLanguage: target.String(),
+			Source:         example.Source,
+			Target:         example.Target,
+			Origin:         targetFileName,
+			Created:        now,
+		})
+	}
+	return toReturn, nil
+}
+
+// memoryResults returns the translations of the source lines that are not
+// in known yet found in the translation memory, by index of the source text,
+// and records them as MemoryHits. Besides exact matches, fuzzy matches at
+// least as similar as the Accept option are used. The matches are final
+// text, with their do-not-translate spans in place.
+func (tr *TranslationRequestBase) memoryResults(known map[int]string) map[int]string {
+	hits := map[int]string{}
+	tr.MemoryHits = nil
+	if tr.Memory == nil {
+		return hits
+	}
+	for i, line := range tr.sourceCues() {
+		if _, ok := known[i]; ok {
+			continue
+		}
+		if target, ok := tr.Memory.Lookup(tr.SourceLanguage, tr.TargetLanguage, line); ok {
+			hits[i] = target
+			tr.reused[i] = true
+			tr.MemoryHits = append(tr.MemoryHits, MemoryHit{Index: i, Score: 1, Source: line})
+			continue
+		}
+		if tr.Memory.Options.Accept <= 0 {
+			continue
+		}
+		if matches := tr.Memory.Fuzzy(tr.SourceLanguage, tr.TargetLanguage, line, tr.Memory.Options.Accept, 1); len(matches) > 0 {
+			hits[i] = matches[0].Target
+			tr.reused[i] = true
+			tr.MemoryHits = append(tr.MemoryHits, MemoryHit{Index: i, Score: matches[0].Score, Source: matches[0].Source})
+		}
+	}
+	if len(hits) > 0 {
+		tr.Cmd.Printf("%d cues found in the translation memory", len(hits))
+	}
+	tr.Usage.MemoryHits = len(tr.MemoryHits)
+	return hits
+}
+
+// memoryReferences returns the fuzzy matches of the source line at index i
+// that are passed to LLM prompts as references
+func (tr *TranslationRequestBase) memoryReferences(i int) []FuzzyMatch {
+	if tr.Memory == nil || tr.Memory.Options.Fuzzy <= 0 || tr.Memory.Options.References <= 0 {
+		return nil
+	}
+	if tr.references == nil {
+		tr.references = map[int][]FuzzyMatch{}
+	}
+	matches, ok := tr.references[i]
+	if !ok {
+		matches = tr.Memory.Fuzzy(tr.SourceLanguage, tr.TargetLanguage, pick(tr.sourceCues(), []int{i})[0], tr.Memory.Options.Fuzzy, tr.Memory.Options.References)
+		tr.references[i] = matches
+	}
+	return matches
+}
+
+// WriteMemoryReport writes a table of the cues translated from the translation memory next to the source file
+func (tr *TranslationRequestBase) WriteMemoryReport(translated []string) error {
+	t := table.NewWriter()
+	t.AppendHeader(table.Row{"#", "Source", "Memory Source", "Translation", "Match"})
+	lines := tr.sourceCues()
+	for _, hit := range tr.MemoryHits {
+		t.AppendRow(table.Row{hit.Index + 1, lines[hit.Index], hit.Source, pick(translated, []int{hit.Index})[0], fmt.Sprintf("%.0f%%", hit.Score*100)})
+	}
+	t.AppendFooter(table.Row{"", "", "", "TM hits", fmt.Sprintf("%d/%d", len(tr.MemoryHits), len(lines))})
+	return tr.WriteReport(fmt.Sprintf("%s-tm", tr.TargetLanguage), t.Render())
+}
@@ -0,0 +1,186 @@
+package models
+
+import (
+	"bytes"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/asticode/go-astisub"
+	"github.com/spf13/cobra"
+	"github.com/stovak/gpt-subtitles/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/language"
+)
+
+const testTMX = `<?xml version="1.0" encoding="UTF-8"?>
+<tmx version="1.4">
+  <header creationtool="SomeCAT" segtype="sentence" o-tmf="x" adminlang="en" srclang="en-US" datatype="plaintext"/>
+  <body>
+    <tu creationdate="20240115T103000Z">
+      <tuv xml:lang="en-US"><seg>Where's my lawyer?</seg></tuv>
+      <tuv xml:lang="es-ES"><seg>¿Dónde está mi abogado?</seg></tuv>
+      <tuv xml:lang="de-DE"><seg>Wo ist mein Anwalt?</seg></tuv>
+    </tu>
+    <tu>
+      <tuv lang="EN-US"><seg>Press <ph x="1">&lt;b&gt;</ph>Enter</seg></tuv>
+      <tuv lang="ES-ES"><seg>Pulse <ph x="1">&lt;b&gt;</ph>Intro</seg></tuv>
+    </tu>
+    <tu>
+      <tuv xml:lang="es-ES"><seg>Solo en español</seg></tuv>
+    </tu>
+    <tu>
+      <tuv xml:lang="en-US"><seg>Press <ph x="2">{1}</ph> to <hi type="b">start</hi> <bpt i="1">&lt;i&gt;</bpt>now<ept i="1">&lt;/i&gt;</ept></seg></tuv>
+      <tuv xml:lang="es-ES"><seg>Pulse <ph x="2">{1}</ph> para <hi type="b">empezar</hi> <bpt i="1">&lt;i&gt;</bpt>ya<ept i="1">&lt;/i&gt;</ept></seg></tuv>
+    </tu>
+  </body>
+</tmx>`
+
+func TestReadTMX(t *testing.T) {
+	units, err := ReadTMX(strings.NewReader(testTMX), "test.tmx")
+	assert.NoError(t, err, "ReadTMX()")
+	assert.Equal(t, []MemoryUnit{
+		{SourceLanguage: "en-US", TargetLanguage: "es-ES", Source: "Where's my lawyer?", Target: "¿Dónde está mi abogado?", Origin: "test.tmx", Created: time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)},
+		{SourceLanguage: "en-US", TargetLanguage: "de-DE", Source: "Where's my lawyer?", Target: "Wo ist mein Anwalt?", Origin: "test.tmx", Created: time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)},
+		{SourceLanguage: "EN-US", TargetLanguage: "ES-ES", Source: "Press Enter", Target: "Pulse Intro", Origin: "test.tmx"},
+		{SourceLanguage: "en-US", TargetLanguage: "es-ES", Source: "Press to start now", Target: "Pulse para empezar ya", Origin: "test.tmx"},
+	}, units)
+
+	_, err = ReadTMX(strings.NewReader("<tmx><body>"), "broken.tmx")
+	assert.Error(t, err, "ReadTMX()")
+}
+
+func TestWriteTMX(t *testing.T) {
+	units := []MemoryUnit{
+		{SourceLanguage: "en", TargetLanguage: "es", Source: "Tom & Jerry <3", Target: "Tom y Jerry <3", Created: time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)},
+	}
+	buf := new(bytes.Buffer)
+	assert.NoError(t, WriteTMX(buf, units, "en"), "WriteTMX()")
+	assert.Contains(t, buf.String(), `<tmx version="1.4">`)
+	assert.Contains(t, buf.String(), `<tuv xml:lang="es">`)
+	assert.Contains(t, buf.String(), `creationdate="20250301T120000Z"`)
+	read, err := ReadTMX(buf, "")
+	assert.NoError(t, err, "ReadTMX()")
+	assert.Equal(t, units, read, "round trip")
+}
+
+func TestTranslationMemory(t *testing.T) {
+	memory, err := LoadTranslationMemory(path.Join(t.TempDir(), "tm.json"))
+	assert.NoError(t, err, "a missing memory is empty")
+	assert.Equal(t, 2, memory.Add(
+		MemoryUnit{SourceLanguage: "en-US", TargetLanguage: "es-ES", Source: "Yes.", Target: "Si."},
+		MemoryUnit{SourceLanguage: "en", TargetLanguage: "de", Source: "Yes.", Target: "Ja."},
+	))
+	assert.Equal(t, 0, memory.Add(MemoryUnit{SourceLanguage: "en", TargetLanguage: "es", Source: "Yes. ", Target: "Sí."}), "same segment")
+
+	target, ok := memory.Lookup(language.English, language.MustParse("es-MX"), "  Yes.")
+	assert.True(t, ok, "Lookup()")
+	assert.Equal(t, "Sí.", target, "the last import wins")
+	_, ok = memory.Lookup(language.English, language.French, "Yes.")
+	assert.False(t, ok, "no French")
+
+	assert.NoError(t, memory.Save(), "Save()")
+	loaded, err := LoadTranslationMemory(memory.FileName)
+	assert.NoError(t, err, "LoadTranslationMemory()")
+	assert.Len(t, loaded.Units, 2)
+}
+
+func TestTranslatedFileLanguage(t *testing.T) {
+	tests := []struct {
+		translated string
+		want       language.Tag
+		wantErr    assert.ErrorAssertionFunc
+	}{
+		{translated: "movies/movie_es.ttml", want: language.Spanish, wantErr: assert.NoError},
+		{translated: "movie_pt-BR.vtt", want: language.BrazilianPortuguese, wantErr: assert.NoError},
+		{translated: "other_es.ttml", want: language.Und, wantErr: assert.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.translated, func(t *testing.T) {
+			got, err := TranslatedFileLanguage("movies/movie.ttml", tt.translated)
+			tt.wantErr(t, err, "TranslatedFileLanguage(%s)", tt.translated)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestGPTTranslateRequest_Translate_memory(t *testing.T) {
+	var prompts []string
+	newFakeOpenAI(t, func(prompt string) string {
+		prompts = append(prompts, prompt)
+		return fakeTranslation(prompt)
+	})
+	tr, err := NewGPTTranslationRequestFromFile(
+		path.Join(util.GetRoot(), "test-fixtures", "TestFixture1.ttml"),
+		"en", "es", &cobra.Command{})
+	assert.NoError(t, err, "NewGPTTranslationRequestFromFile()")
+	gpt := tr.(*GPTTranslationRequest)
+	gpt.Memory = &TranslationMemory{}
+	gpt.Memory.Add(
+		MemoryUnit{SourceLanguage: "en", TargetLanguage: "es", Source: "Where's my lawyer?", Target: "¿Dónde está mi abogado?"},
+		MemoryUnit{SourceLanguage: "en", TargetLanguage: "es", Source: "Ask [[Walter]] now", Target: "Pregúntale a Walter ya"},
+	)
+	assert.NoError(t, gpt.Parse(), "Parse()")
+	gpt.Subtitles.Items[3].Lines = []astisub.Line{{Items: []astisub.LineItem{{Text: "Ask [[Walter]] now"}}}}
+	assert.NoError(t, gpt.Translate(), "Translate()")
+	assert.Len(t, prompts, 2)
+	assert.NotContains(t, prompts[0], "Where's my lawyer?", "exact matches are not sent to the engine")
+	assert.Equal(t, []MemoryHit{{Index: 3, Score: 1, Source: "Ask [[Walter]] now"}, {Index: 9, Score: 1, Source: "Where's my lawyer?"}}, gpt.MemoryHits)
+	assert.Equal(t, 2, gpt.GetUsage().MemoryHits)
+	translated, err := gpt.GetTranslated()
+	assert.NoError(t, err, "GetTranslated()")
+	assert.Equal(t, "¿Dónde está mi abogado?", translated.Items[9].String())
+	assert.Equal(t, "Pregúntale a Walter ya", translated.Items[3].String(), "do-not-translate spans are not restored twice")
+	assert.Equal(t, "ES Legally.", translated.Items[11].String())
+}
+
+func TestTranslationMemory_Fuzzy(t *testing.T) {
+	memory := &TranslationMemory{}
+	memory.Add(
+		MemoryUnit{SourceLanguage: "en", TargetLanguage: "es", Source: "Where's my lawyer?", Target: "¿Dónde está mi abogado?"},
+		MemoryUnit{SourceLanguage: "en", TargetLanguage: "es", Source: "Where is my lawyer, detective?", Target: "¿Dónde está mi abogado, detective?"},
+		MemoryUnit{SourceLanguage: "en", TargetLanguage: "es", Source: "I want my camera back.", Target: "Quiero que me devuelvan mi cámara."},
+		MemoryUnit{SourceLanguage: "en", TargetLanguage: "de", Source: "Where's my lawyer!", Target: "Wo ist mein Anwalt!"},
+	)
+	matches := memory.Fuzzy(language.English, language.Spanish, "Where's my lawyer now?", 0.5, 3)
+	assert.Len(t, matches, 2)
+	assert.Equal(t, "Where's my lawyer?", matches[0].Source, "best match first")
+	assert.Greater(t, matches[0].Score, matches[1].Score)
+	assert.Len(t, memory.Fuzzy(language.English, language.Spanish, "Where's my lawyer now?", 0.5, 1), 1, "limit")
+	assert.Empty(t, memory.Fuzzy(language.English, language.Spanish, "Legally.", 0.5, 3))
+}
+
+func TestGPTTranslateRequest_Translate_fuzzyMemory(t *testing.T) {
+	var prompts []string
+	newFakeOpenAI(t, func(prompt string) string {
+		prompts = append(prompts, prompt)
+		return fakeTranslation(prompt)
+	})
+	tr, err := NewGPTTranslationRequestFromFile(
+		path.Join(util.GetRoot(), "test-fixtures", "TestFixture1.ttml"),
+		"en", "es", &cobra.Command{})
+	assert.NoError(t, err, "NewGPTTranslationRequestFromFile()")
+	gpt := tr.(*GPTTranslationRequest)
+	gpt.Memory = &TranslationMemory{Options: MemoryOptions{Fuzzy: 0.6, References: 2, Accept: 0.95}}
+	gpt.Memory.Add(
+		MemoryUnit{SourceLanguage: "en", TargetLanguage: "es", Source: "Where's my lawyer, detective?", Target: "¿Dónde está mi abogado, detective?"},
+		MemoryUnit{SourceLanguage: "en", TargetLanguage: "es", Source: "legally", Target: "Legalmente"},
+		MemoryUnit{SourceLanguage: "en", TargetLanguage: "es", Source: "Ask [[Walter]] now!", Target: "¡Pregúntale a Walter ya!"},
+	)
+	assert.NoError(t, gpt.Parse(), "Parse()")
+	gpt.Subtitles.Items[3].Lines = []astisub.Line{{Items: []astisub.LineItem{{Text: "Ask [[Walter]] now"}}}}
+	assert.NoError(t, gpt.Translate(), "Translate()")
+	assert.Len(t, gpt.MemoryHits, 2, "a match above the accept threshold is used as translation")
+	assert.Equal(t, 3, gpt.MemoryHits[0].Index)
+	assert.Equal(t, 11, gpt.MemoryHits[1].Index)
+	assert.Equal(t, "legally", gpt.MemoryHits[1].Source)
+	assert.Contains(t, prompts[0], "These similar lines were translated before")
+	assert.Contains(t, prompts[0], "Where's my lawyer, detective? => ¿Dónde está mi abogado, detective?")
+	assert.NotContains(t, prompts[1], "These similar lines were translated before", "no references in the second batch")
+	translated, err := gpt.GetTranslated()
+	assert.NoError(t, err, "GetTranslated()")
+	assert.Equal(t, "Legalmente", translated.Items[11].String())
+	assert.Equal(t, "¡Pregúntale a Walter ya!", translated.Items[3].String(), "do-not-translate spans are not restored twice")
+	assert.Equal(t, "ES Where's my lawyer?", translated.Items[9].String())
+}
@@ -15,9 +15,15 @@ import (
 type TranslationRequest interface {
 	GetSourceLanguage() language.Tag
 	GetSourceText() []string
+	GetBase() *TranslationRequestBase
 	GetCmd() *cobra.Command
 	GetTranslatedText() []string
 	GetTargetLanguage() language.Tag
+	GetUsage() Usage
+	GetPassUsage() []Usage
+	EstimatePasses() ([]Usage, error)
+	GetPostEdits() []Edit
+	DryRun() (Usage, []string, error)
 	GetTranslated() (*astisub.Subtitles, error)
 	Parse() error
 	String() string
@@ -33,6 +39,54 @@ type TranslationRequestBase struct {
 	Extension        string
 	Subtitles        *astisub.Subtitles
 	Cmd              *cobra.Command
+	// Examples are few-shot source/target pairs for the language pair, used by LLM engines
+	Examples []Example
+	// Formality is the register to translate into; engines without native support ignore it
+	Formality Formality
+	// Glossary holds the required target renderings for source terms
+	Glossary []GlossaryTerm
+	// Spans protects do-not-translate spans with placeholders while the text is with an engine
+	Spans *SpanProtector
+	// Bible describes the show, its characters and their relationships for LLM engines
+	Bible *ShowBible
+	// Usage counts the requests, tokens and characters sent to the engine
+	Usage Usage
+	// Reviewer runs an optional LLM self-review pass over the translation
+	Reviewer *Reviewer
+	// Quality runs an optional LLM pass estimating the quality of every cue
+	Quality *QualityEstimator
+	// Length limits the number of characters of every cue by its duration
+	Length *LengthLimits
+	// Shortener sends the cues longer than their limit back to be shortened
+	Shortener *Shortener
+	// Cache keeps the engine results of every cue so they are only paid for once
+	Cache *Cache
+	// Dedupe sends repeated source texts to the engine only once
+	Dedupe *Dedupe
+	// Memory holds approved translations that are used instead of the engine for exact matches
+	Memory *TranslationMemory
+	// MemoryHits are the cues of the source text translated from the memory
+	MemoryHits []MemoryHit
+	references map[int][]FuzzyMatch
+	// Series keeps recurring lines and names consistent across the episodes of a series
+	Series      *SeriesMemory
+	established []GlossaryTerm
+	// Checkpoint persists the finished batches so an interrupted run can be resumed
+	Checkpoint *Checkpoint
+	// Resume continues from the checkpoint of an interrupted run
+	Resume bool
+	// Patch writes the translation by replacing only the text of the cues in the source document
+	Patch bool
+	// Previous is the manifest of the last translation, whose unchanged cues are carried over
+	Previous *Manifest
+	// prompt identifies the prompt template of an LLM engine, so a manifest of another template is not reused
+	prompt string
+	// reused are the indexes of the cues translated from the previous
+	// translation or the translation memory, whose spans need no restoring
+	reused map[int]bool
+	// Spending is checked before every request to the LLM; an error stops the
+	// translation, keeping the finished batches in the checkpoint
+	Spending func() error
 }
 
 func (tr *TranslationRequestBase) ParseSourceTarget(source string, target string) {
@@ -42,23 +96,50 @@ func (tr *TranslationRequestBase) ParseSourceTarget(source string, target string
 
 func (tr *TranslationRequestBase) Parse() error {
 	var err error
-	tr.Subtitles, err = astisub.OpenFile(tr.SubtitleFileName)
+	tr.Subtitles, err = OpenSubtitles(tr.SubtitleFileName)
 	tr.Extension = filepath.Ext(tr.SubtitleFileName)
 	return err
 }
 
-// GetSourceText returns a string of all the lines in the subtitle file
+// GetSourceText returns the text of every cue in the subtitle file, its
+// lines joined and its styled spans tagged, with the do-not-translate spans
+// replaced by placeholders
 func (tr *TranslationRequestBase) GetSourceText() []string {
 	var toReturn []string
 	for _, item := range tr.Subtitles.Items {
-		for _, line := range item.Lines {
-			toReturn = append(toReturn, line.String())
-		}
+		text, _ := taggedText(item, nil)
+		toReturn = append(toReturn, tr.spans().Protect(text))
 	}
 	tr.Cmd.Printf("Split text: %+v", toReturn)
 	return toReturn
 }
 
+// sourceCues returns the plain text of every cue of the source
+func (tr *TranslationRequestBase) sourceCues() []string {
+	var toReturn []string
+	for _, item := range tr.Subtitles.Items {
+		toReturn = append(toReturn, cueText(item))
+	}
+	return toReturn
+}
+
+func (tr *TranslationRequestBase) spans() *SpanProtector {
+	if tr.Spans == nil {
+		tr.Spans = &SpanProtector{}
+	}
+	return tr.Spans
+}
+
+// restoreText puts the do-not-translate spans of the source cue at index i
+// back into its translation. Reused translations are final text already.
+func (tr *TranslationRequestBase) restoreText(i int, translated string) (string, error) {
+	if tr.reused[i] {
+		return translated, nil
+	}
+	text, _ := taggedText(tr.Subtitles.Items[i], nil)
+	return tr.spans().Restore(tr.spans().Protect(text), translated)
+}
+
 func (tr *TranslationRequestBase) String() string {
 	buf := new(strings.Builder)
 	err := tr.Subtitles.WriteToTTML(buf)
@@ -103,11 +184,27 @@ func (tr *TranslationRequestBase) WriteErrorDiff(translatedText []string) error
 	return tr.WriteToFile(fmt.Sprintf("-%s-%s", "error", tr.TargetLanguage), t.Render())
 }
 
-func (tr *TranslationRequestBase) WriteToFile(variant string, contents string) error {
+// TranslatedFileName returns the name of the file a variant of fileName is written to,
+// e.g. movie.ttml => movie_es.ttml
+func TranslatedFileName(fileName string, variant string) string {
+	return strings.Replace(
+		fileName,
+		filepath.Ext(fileName),
+		fmt.Sprintf("_%s.ttml", variant), 1)
+}
+
+// WriteReport writes a plain text report next to the source file, e.g. movie_es-glossary.txt
+func (tr *TranslationRequestBase) WriteReport(variant string, contents string) error {
 	fileName := strings.Replace(
 		tr.SubtitleFileName,
 		tr.Extension,
-		fmt.Sprintf("_%s.ttml", variant), 1)
+		fmt.Sprintf("_%s.txt", variant), 1)
+	tr.Cmd.Printf("Writing report %s to %s", variant, fileName)
+	return os.WriteFile(fileName, []byte(contents), 0644)
+}
+
+func (tr *TranslationRequestBase) WriteToFile(variant string, contents string) error {
+	fileName := TranslatedFileName(tr.SubtitleFileName, variant)
 	tr.Cmd.Printf("Writing results %s to %s", variant, fileName)
 	return os.WriteFile(fileName, []byte(contents), 0700)
 }
@@ -115,3 +212,84 @@ func (tr *TranslationRequestBase) WriteToFile(variant string, contents string) e
 func (tr *TranslationRequestBase) GetCmd() *cobra.Command {
 	return tr.Cmd
 }
+
+// GetUsage returns the usage of the engine so far, labelled with the file and language pair
+func (tr *TranslationRequestBase) GetUsage() Usage {
+	return tr.labelUsage(tr.Usage)
+}
+
+// GetPassUsage returns the usage of the passes run after the engine, such as the review pass
+func (tr *TranslationRequestBase) GetPassUsage() []Usage {
+	var toReturn []Usage
+	if tr.Reviewer != nil && tr.Reviewer.Usage.Requests > 0 {
+		toReturn = append(toReturn, tr.labelUsage(tr.Reviewer.Usage))
+	}
+	if tr.Shortener != nil && tr.Shortener.Usage.Requests > 0 {
+		toReturn = append(toReturn, tr.labelUsage(tr.Shortener.Usage))
+	}
+	if tr.Quality != nil && tr.Quality.Usage.Requests > 0 {
+		toReturn = append(toReturn, tr.labelUsage(tr.Quality.Usage))
+	}
+	return toReturn
+}
+
+// EstimatePasses estimates the usage of the passes run after the engine. The
+// cues longer than their limit in the source are expected to be shortened.
+func (tr *TranslationRequestBase) EstimatePasses() ([]Usage, error) {
+	var toReturn []Usage
+	estimate := func(pass *cuePass, cues []int) error {
+		usage, err := pass.estimate(tr, cues)
+		if err == nil && usage.Requests > 0 {
+			toReturn = append(toReturn, tr.labelUsage(usage))
+		}
+		return err
+	}
+	var err error
+	if tr.Reviewer != nil {
+		err = estimate(&tr.Reviewer.cuePass, allCues(tr, tr.Subtitles))
+	}
+	if err == nil && tr.Shortener != nil && tr.Length != nil {
+		err = estimate(&tr.Shortener.cuePass, tr.Length.Overflowing(tr.Subtitles, tr.Subtitles))
+	}
+	if err == nil && tr.Quality != nil {
+		err = estimate(&tr.Quality.cuePass, allCues(tr, tr.Subtitles))
+	}
+	return toReturn, err
+}
+
+// GetPostEdits returns the corrections an engine made to its own draft, none by default
+func (tr *TranslationRequestBase) GetPostEdits() []Edit {
+	return nil
+}
+
+// checkSpending returns the error of Spending, if set
+func (tr *TranslationRequestBase) checkSpending() error {
+	if tr.Spending == nil {
+		return nil
+	}
+	return tr.Spending()
+}
+
+func (tr *TranslationRequestBase) labelUsage(usage Usage) Usage {
+	usage.File = filepath.Base(tr.SubtitleFileName)
+	usage.SourceLanguage = tr.SourceLanguage.String()
+	usage.TargetLanguage = tr.TargetLanguage.String()
+	return usage
+}
+
+func (tr *TranslationRequestBase) GetBase() *TranslationRequestBase {
+	return tr
+}
+
+// FormalityInstruction returns the prompt instruction for the requested formality, if any
+func (tr *TranslationRequestBase) FormalityInstruction() string {
+	return tr.Formality.Instruction(tr.TargetLanguage)
+}
+
+// AddExamples appends few-shot examples, keeping at most limit of them when limit is positive
+func (tr *TranslationRequestBase) AddExamples(examples []Example, limit int) {
+	tr.Examples = append(tr.Examples, examples...)
+	if limit > 0 && len(tr.Examples) > limit {
+		tr.Examples = tr.Examples[:limit]
+	}
+}
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+// Usage is what a translation request consumed from its engine
+type Usage struct {
+	File             string  `json:"file"`
+	SourceLanguage   string  `json:"source_language"`
+	TargetLanguage   string  `json:"target_language"`
+	Engine           string  `json:"engine"`
+	Model            string  `json:"model"`
+	Requests         int     `json:"requests"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	Characters       int     `json:"characters"`
+	MemoryHits       int     `json:"tm_hits"`
+	Deduplicated     int     `json:"deduplicated"`
+	Cost             float64 `json:"cost"`
+}
+
+// Add accumulates the counters of other into u
+func (u *Usage) Add(other Usage) {
+	u.Requests += other.Requests
+	u.PromptTokens += other.PromptTokens
+	u.CompletionTokens += other.CompletionTokens
+	u.Characters += other.Characters
+	u.MemoryHits += other.MemoryHits
+	u.Deduplicated += other.Deduplicated
+	u.Cost += other.Cost
+}
+
+// EstimateTokens approximates the number of tokens of text for pricing dry
+// runs, using the rule of thumb of about four characters per token
+func EstimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+// Rate is the price of a model. Token prices are per 1,000 tokens and
+// character prices are per 1,000,000 characters.
+type Rate struct {
+	Prompt     float64 `mapstructure:"prompt" json:"prompt"`
+	Completion float64 `mapstructure:"completion" json:"completion"`
+	Characters float64 `mapstructure:"characters" json:"characters"`
+}
+
+// RateTable maps model names to their rates
+type RateTable map[string]Rate
+
+// DefaultRates are used for models without a configured rate
+var DefaultRates = RateTable{
+	"gpt-4": {Prompt: 0.03, Completion: 0.06},
+	"nmt":   {Characters: 20},
+}
+
+// Price sets the cost of the usage from the rate of its model. It reports
+// false when there is no rate for the model.
+func (r RateTable) Price(u *Usage) bool {
+	rate, ok := r[u.Model]
+	if !ok {
+		rate, ok = DefaultRates[u.Model]
+	}
+	if !ok {
+		return false
+	}
+	u.Cost = float64(u.PromptTokens)/1000*rate.Prompt +
+		float64(u.CompletionTokens)/1000*rate.Completion +
+		float64(u.Characters)/1000000*rate.Characters
+	return true
+}
+
+// UsageReport is the usage of every request of a run
+type UsageReport []Usage
+
+// Total sums the usage of all requests
+func (r UsageReport) Total() Usage {
+	toReturn := Usage{File: "Total"}
+	for _, u := range r {
+		toReturn.Add(u)
+	}
+	return toReturn
+}
+
+// JSON renders the report with its total
+func (r UsageReport) JSON() (string, error) {
+	out, err := json.MarshalIndent(struct {
+		Usage []Usage `json:"usage"`
+		Total Usage   `json:"total"`
+	}{r, r.Total()}, "", "    ")
+	return string(out), err
+}
+
+// Table renders the report as a text table
+func (r UsageReport) Table() string {
+	t := table.NewWriter()
+	t.AppendHeader(table.Row{"File", "Language", "Engine", "Model", "Requests", "Prompt Tokens", "Completion Tokens", "Characters", "TM Hits", "Repeats", "Cost"})
+	for _, u := range r {
+		t.AppendRow(table.Row{u.File, fmt.Sprintf("%s => %s", u.SourceLanguage, u.TargetLanguage), u.Engine, u.Model, u.Requests, u.PromptTokens, u.CompletionTokens, u.Characters, u.MemoryHits, u.Deduplicated, fmt.Sprintf("$%.4f", u.Cost)})
+	}
+	total := r.Total()
+	t.AppendFooter(table.Row{total.File, "", "", "", total.Requests, total.PromptTokens, total.CompletionTokens, total.Characters, total.MemoryHits, total.Deduplicated, fmt.Sprintf("$%.4f", total.Cost)})
+	return t.Render()
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateTable_Price(t *testing.T) {
+	tests := []struct {
+		name   string
+		rates  RateTable
+		usage  Usage
+		want   float64
+		wantOk bool
+	}{
+		{
+			name:   "Price-configured",
+			rates:  RateTable{"gpt-4": {Prompt: 0.01, Completion: 0.02}},
+			usage:  Usage{Model: "gpt-4", PromptTokens: 2000, CompletionTokens: 1000},
+			want:   0.04,
+			wantOk: true,
+		},
+		{
+			name:   "Price-default",
+			rates:  RateTable{},
+			usage:  Usage{Model: "nmt", Characters: 500000},
+			want:   10,
+			wantOk: true,
+		},
+		{
+			name:   "Price-unknown",
+			rates:  RateTable{},
+			usage:  Usage{Model: "unknown", Characters: 500000},
+			want:   0,
+			wantOk: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok := tt.rates.Price(&tt.usage)
+			assert.Equal(t, tt.wantOk, ok, "Price()")
+			assert.InDelta(t, tt.want, tt.usage.Cost, 0.000001, "Cost")
+		})
+	}
+}
+
+func TestUsageReport(t *testing.T) {
+	report := UsageReport{
+		{File: "a.ttml", TargetLanguage: "es", Model: "gpt-4", Requests: 2, PromptTokens: 10, Cost: 1.5},
+		{File: "a.ttml", TargetLanguage: "fr", Model: "gpt-4", Requests: 1, PromptTokens: 5, Cost: 0.25},
+	}
+	total := report.Total()
+	assert.Equal(t, 3, total.Requests)
+	assert.Equal(t, 15, total.PromptTokens)
+	assert.InDelta(t, 1.75, total.Cost, 0.000001)
+	out, err := report.JSON()
+	assert.NoError(t, err, "JSON()")
+	assert.Contains(t, out, `"target_language": "fr"`)
+	assert.Contains(t, report.Table(), "$1.7500")
+}
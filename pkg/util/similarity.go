@@ -0,0 +1,60 @@
+package util
+
+import (
+	"strings"
+	"unicode"
+)
+
+// NormalizeText lowercases text and reduces it to letters, digits and single spaces
+func NormalizeText(text string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range strings.ToLower(text) {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			if space && b.Len() > 0 {
+				b.WriteRune(' ')
+			}
+			b.WriteRune(r)
+			space = false
+			continue
+		}
+		space = true
+	}
+	return b.String()
+}
+
+// Similarity scores how alike two texts are from 0 (nothing in common) to 1
+// (identical after normalisation), using the Dice coefficient of their
+// character trigrams. Trigrams tolerate inflection and small wording changes
+// better than comparing whole words.
+func Similarity(a string, b string) float64 {
+	a, b = NormalizeText(a), NormalizeText(b)
+	if a == b {
+		return 1
+	}
+	ta, tb := trigrams(a), trigrams(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+	counts := map[string]int{}
+	for _, t := range ta {
+		counts[t]++
+	}
+	shared := 0
+	for _, t := range tb {
+		if counts[t] > 0 {
+			counts[t]--
+			shared++
+		}
+	}
+	return float64(2*shared) / float64(len(ta)+len(tb))
+}
+
+func trigrams(text string) []string {
+	runes := []rune(" " + text + " ")
+	var toReturn []string
+	for i := 0; i+3 <= len(runes); i++ {
+		toReturn = append(toReturn, string(runes[i:i+3]))
+	}
+	return toReturn
+}
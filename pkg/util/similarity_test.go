@@ -0,0 +1,34 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeText(t *testing.T) {
+	assert.Equal(t, "i d like to report an emergency", NormalizeText("  I'd like to report... an EMERGENCY!"))
+	assert.Equal(t, "sí señor", NormalizeText("¡Sí, señor!"))
+}
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		min  float64
+		max  float64
+	}{
+		{name: "Similarity-identical", a: "Where's my lawyer?", b: "where's my lawyer", min: 1, max: 1},
+		{name: "Similarity-close", a: "Where's my lawyer?", b: "Where is my attorney?", min: 0.3, max: 0.8},
+		{name: "Similarity-unrelated", a: "Where's my lawyer?", b: "I like the part about free press.", min: 0, max: 0.2},
+		{name: "Similarity-empty", a: "", b: "Yes.", min: 0, max: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Similarity(tt.a, tt.b)
+			assert.GreaterOrEqualf(t, got, tt.min, "Similarity(%s, %s)", tt.a, tt.b)
+			assert.LessOrEqualf(t, got, tt.max, "Similarity(%s, %s)", tt.a, tt.b)
+		})
+	}
+}
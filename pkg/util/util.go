@@ -41,3 +41,15 @@ func TrimSlice(slice []string) []string {
 	}
 	return toReturn
 }
+
+// ExpandHome replaces a leading ~ in a path from the config with the home directory
+func ExpandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return home + strings.TrimPrefix(path, "~")
+}
@@ -0,0 +1,16 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandHome(t *testing.T) {
+	t.Setenv("HOME", "/home/walter")
+	assert.Equal(t, "/home/walter", ExpandHome("~"))
+	assert.Equal(t, "/home/walter/.gpt-subtitles/tm", ExpandHome("~/.gpt-subtitles/tm"))
+	assert.Equal(t, "/data/$PROJECT/tm", ExpandHome("/data/$PROJECT/tm"))
+	assert.Equal(t, "/home/walter/$PROJECT/tm", ExpandHome("~/$PROJECT/tm"))
+	assert.Equal(t, "~walter/tm", ExpandHome("~walter/tm"))
+}